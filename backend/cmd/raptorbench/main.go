@@ -0,0 +1,276 @@
+// Command raptorbench measures per-query latency of the routing engine on the
+// full Casablanca network, and the throughput and allocations of the same
+// queries run one at a time and one per core.
+//
+// The network comes either from the database, exactly as the server loads it,
+// from a snapshot written by cmd/snapshot, or from the OSM export in scrapers/
+// with synthetic timetables, so it can run without a database:
+//
+//	go run ./cmd/raptorbench -osm ../scrapers/osm_casablanca_transit.json
+//	go run ./cmd/raptorbench -db postgres://... -mode range
+//	go run ./cmd/raptorbench -snapshot routing.snap
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antigravity/morocco-transport/internal/routing"
+	"github.com/antigravity/morocco-transport/internal/streets"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	dbURL := flag.String("db", "", "load the network from this database")
+	osmPath := flag.String("osm", "../scrapers/osm_casablanca_transit.json", "load the network from this OSM export when -db is not set")
+	snapshotPath := flag.String("snapshot", "", "load the network from this snapshot")
+	streetsPath := flag.String("streets", "", "walk along the streets of this OSM extract (.osm, .pbf or Overpass .json)")
+	mode := flag.String("mode", "route", "query type: route, arrive-by, range, cheapest or cost")
+	queries := flag.Int("queries", 1000, "number of random origin/destination pairs")
+	seed := flag.Int64("seed", 1, "random seed for the origin/destination pairs")
+	flag.IntVar(&benchBudget.Stops, "budget-stops", 0, "stops a query may scan, 0 for routing.DefaultBudget")
+	flag.DurationVar(&benchBudget.Time, "budget-time", 0, "time a query may take, 0 for routing.DefaultBudget")
+	flag.Parse()
+
+	var data *routing.RaptorData
+	var graph *streets.Graph
+	var err error
+	start := time.Now()
+	if *streetsPath != "" {
+		if graph, err = streets.Load(*streetsPath); err != nil {
+			log.Fatal("Failed to load street network: ", err)
+		}
+	}
+	switch {
+	case *snapshotPath != "":
+		if data, err = routing.LoadSnapshot(*snapshotPath); err == nil {
+			data.Streets = graph
+		}
+	case *dbURL != "":
+		data, err = loadDB(*dbURL, graph)
+	default:
+		data, err = loadOSM(*osmPath, graph)
+	}
+	if err != nil {
+		log.Fatal("Failed to load network: ", err)
+	}
+	engine := routing.NewRaptor(data)
+	fmt.Printf("network: %d stops, %d routes (loaded in %v)\n", len(data.Stops), len(data.Routes), time.Since(start).Round(time.Millisecond))
+
+	query, ok := queryFuncs[*mode]
+	if !ok {
+		log.Fatalf("unknown mode %q", *mode)
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	// Origins and destinations at random stops, reaching every stop within walking distance
+	place := func(name string) routing.Place {
+		s := data.Stops[rng.Intn(len(data.Stops))]
+		return engine.PlaceAt(name, s.Lat, s.Lon, routing.DefaultMaxWalk)
+	}
+	pairs := make([][2]routing.Place, *queries)
+	for i := range pairs {
+		pairs[i] = [2]routing.Place{place("Origin"), place("Destination")}
+	}
+
+	// Latency distribution, one timed run per pair
+	latencies := make([]time.Duration, len(pairs))
+	found, stopped := 0, 0
+	for i, p := range pairs {
+		t := time.Now()
+		journeys, err := query(engine, p[0], p[1])
+		latencies[i] = time.Since(t)
+		if len(journeys) > 0 {
+			found++
+		}
+		if err != nil {
+			stopped++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Printf("%s: %d queries, %d with a journey, %d stopped over budget\n", *mode, len(pairs), found, stopped)
+	fmt.Printf("  p50 %v  p95 %v  p99 %v  max %v\n",
+		percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 99), latencies[len(latencies)-1])
+
+	// Throughput and allocations over the same pairs
+	res := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			p := pairs[i%len(pairs)]
+			query(engine, p[0], p[1])
+		}
+	})
+	fmt.Printf("  %s\n", res.String()+" "+res.MemString())
+
+	// The same with a query per core, as under peak load, where the
+	// allocations show up as garbage collections
+	var gcs uint32
+	res = testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		var next atomic.Int64
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				p := pairs[int(next.Add(1))%len(pairs)]
+				query(engine, p[0], p[1])
+			}
+		})
+		runtime.ReadMemStats(&after)
+		gcs = after.NumGC - before.NumGC
+	})
+	fmt.Printf("  parallel x%d: %s %s, %d GC cycles\n", runtime.GOMAXPROCS(0), res.String(), res.MemString(), gcs)
+}
+
+const (
+	benchDeparture = 8 * 3600 // 08:00
+	benchDay       = "weekday"
+)
+
+// benchBudget is the query budget of every mode, set from the flags.
+var benchBudget routing.Budget
+
+var queryFuncs = map[string]func(r *routing.Raptor, from, to routing.Place) ([]*routing.Journey, error){
+	"route": func(r *routing.Raptor, from, to routing.Place) ([]*routing.Journey, error) {
+		return r.FindRoute(context.Background(), from, to, benchDeparture, routing.ServiceDays(benchDay), routing.RouteOptions{Budget: benchBudget})
+	},
+	"arrive-by": func(r *routing.Raptor, from, to routing.Place) ([]*routing.Journey, error) {
+		return r.FindRouteArriveBy(context.Background(), from, to, benchDeparture+3600, routing.ServiceDays(benchDay), routing.RouteOptions{Budget: benchBudget})
+	},
+	"range": func(r *routing.Raptor, from, to routing.Place) ([]*routing.Journey, error) {
+		return r.FindRange(context.Background(), from, to, benchDeparture, benchDeparture+3600, routing.ServiceDays(benchDay), routing.RouteOptions{Budget: benchBudget})
+	},
+	"cheapest": func(r *routing.Raptor, from, to routing.Place) ([]*routing.Journey, error) {
+		return r.FindRoute(context.Background(), from, to, benchDeparture, routing.ServiceDays(benchDay), routing.RouteOptions{Optimize: routing.OptimizeCheapest, Budget: benchBudget})
+	},
+	"cost": func(r *routing.Raptor, from, to routing.Place) ([]*routing.Journey, error) {
+		return r.FindRoute(context.Background(), from, to, benchDeparture, routing.ServiceDays(benchDay), routing.RouteOptions{Cost: &routing.DefaultCostModel, Budget: benchBudget})
+	},
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+func loadDB(dbURL string, graph *streets.Graph) (*routing.RaptorData, error) {
+	pool, err := pgxpool.New(context.Background(), dbURL)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+	loader := routing.NewLoader(pool)
+	loader.Streets = graph
+	return loader.LoadData(context.Background())
+}
+
+// osmExport is the subset of scrapers/osm_casablanca_transit.json used here.
+type osmExport struct {
+	Lines []struct {
+		OSMID        int64
+		Ref          string
+		Operator     string
+		RouteType    string
+		StationOrder []int64
+	}
+	Stations []struct {
+		OSMID int64
+		Name  string
+		Lat   float64
+		Lon   float64
+	}
+}
+
+// loadOSM builds the network from the OSM export. The export has no
+// timetables, so every line runs every few minutes from 06:00 to 22:00 and
+// its segments take as long as the loader estimates from the stop positions.
+// Footpaths join stops within 300 m, walked at 1 m/s like the loader's.
+func loadOSM(path string, graph *streets.Graph) (*routing.RaptorData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var export osmExport
+	if err := json.Unmarshal(raw, &export); err != nil {
+		return nil, err
+	}
+
+	data := &routing.RaptorData{
+		Transfers:    make(map[routing.StopID][]routing.Transfer),
+		DBIDToStopID: make(map[int]routing.StopID),
+		Streets:      graph,
+	}
+	byOSM := make(map[int64]routing.StopID)
+	for _, s := range export.Stations {
+		id := routing.StopID(len(data.Stops))
+		byOSM[s.OSMID] = id
+		data.Stops = append(data.Stops, routing.Stop{ID: id, DBID: len(data.Stops) + 1, Name: s.Name, Lat: s.Lat, Lon: s.Lon})
+		data.DBIDToStopID[len(data.Stops)] = id
+	}
+
+	headways := map[string]int{"tram": 8 * 60, "busway": 10 * 60}
+	for _, l := range export.Lines {
+		var stops []routing.StopID
+		for _, osmID := range l.StationOrder {
+			if id, ok := byOSM[osmID]; ok && (len(stops) == 0 || stops[len(stops)-1] != id) {
+				stops = append(stops, id)
+			}
+		}
+		if len(stops) < 2 {
+			continue
+		}
+
+		headway, ok := headways[l.RouteType]
+		if !ok {
+			headway = 15 * 60
+		}
+		route := routing.Route{
+			ID:       routing.RouteID(len(data.Routes)),
+			Stops:    stops,
+			LineID:   int(l.OSMID),
+			LineCode: l.Ref,
+			LineType: l.RouteType,
+			FareID:   -1,
+			Price:    5.0,
+		}
+		trip := routing.Trip{ServiceId: benchDay, Headway: headway}
+		t := 6 * 3600
+		for i := range stops {
+			if i > 0 {
+				t += routing.DefaultSpeeds.StraightTravelTime(l.RouteType, &data.Stops[stops[i-1]], &data.Stops[stops[i]])
+			}
+			trip.StopTimes = append(trip.StopTimes, routing.StopTime{Arrival: t, Departure: t})
+		}
+		services := routing.NewTripTable(nil)
+		services.Frequencies = []routing.Frequency{{Start: 6 * 3600, End: 22 * 3600, Headway: headway, Trip: trip}}
+		route.Services = map[string]*routing.TripTable{benchDay: services}
+		data.Routes = append(data.Routes, route)
+	}
+
+	if graph != nil {
+		data.StreetTransfers(300)
+		return data, nil
+	}
+	for i := range data.Stops {
+		for j := range data.Stops {
+			if i == j {
+				continue
+			}
+			a, b := &data.Stops[i], &data.Stops[j]
+			if d := routing.DistanceMeters(a.Lat, a.Lon, b.Lat, b.Lon); d <= 300 {
+				data.Transfers[a.ID] = append(data.Transfers[a.ID], routing.Transfer{ToStop: b.ID, TimeSeconds: int(d), Meters: int(d)})
+			}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/antigravity/morocco-transport/internal/routing"
+)
+
+// AdminHandler serves the operations endpoints. They require the admin token
+// as a bearer token and are disabled when no token is configured.
+type AdminHandler struct {
+	Engine *routing.Engine
+	Token  string
+}
+
+func NewAdminHandler(engine *routing.Engine, token string) *AdminHandler {
+	return &AdminHandler{Engine: engine, Token: token}
+}
+
+// Authorize rejects requests without the admin token.
+func (h *AdminHandler) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if h.Token == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reload rebuilds the routing data from the database in the background and
+// swaps it in once ready. Queries keep using the current data until then.
+func (h *AdminHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	started := h.Engine.Reload()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"started": started, // false if it follows the reload already running
+		"status":  h.Engine.Status(),
+	})
+}
+
+// ReloadStatus reports the version of the routing data and the last reload.
+func (h *AdminHandler) ReloadStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Engine.Status())
+}
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"github.com/antigravity/morocco-transport/internal/repository"
 	"github.com/antigravity/morocco-transport/internal/routing"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
@@ -16,11 +19,11 @@ import (
 
 type TransportHandler struct {
 	Repo   *repository.LineRepository
-	Raptor *routing.Raptor
+	Engine *routing.Engine
 }
 
-func NewTransportHandler(repo *repository.LineRepository, raptor *routing.Raptor) *TransportHandler {
-	return &TransportHandler{Repo: repo, Raptor: raptor}
+func NewTransportHandler(repo *repository.LineRepository, engine *routing.Engine) *TransportHandler {
+	return &TransportHandler{Repo: repo, Engine: engine}
 }
 
 func (h *TransportHandler) GetAllLines(w http.ResponseWriter, r *http.Request) {
@@ -54,90 +57,239 @@ func (h *TransportHandler) GetLineDetails(w http.ResponseWriter, r *http.Request
 }
 
 func (h *TransportHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
+	// The whole query runs on one snapshot, even if the data is reloaded meanwhile
+	raptor := h.Engine.Raptor()
+
 	fromLat, _ := strconv.ParseFloat(r.URL.Query().Get("from_lat"), 64)
 	fromLon, _ := strconv.ParseFloat(r.URL.Query().Get("from_lon"), 64)
 	toLat, _ := strconv.ParseFloat(r.URL.Query().Get("to_lat"), 64)
 	toLon, _ := strconv.ParseFloat(r.URL.Query().Get("to_lon"), 64)
 	
-	// Parse time (in seconds from midnight) and day type
-	departureTime := 8*3600 + 30*60 // Default: 08:30
-	if timeParam := r.URL.Query().Get("time"); timeParam != "" {
-		if parsed, err := strconv.Atoi(timeParam); err == nil && parsed >= 0 && parsed < 86400 {
+	// Parse time and service date, in Casablanca time. time is "now" (the
+	// default), an ISO-8601 datetime (2026-03-02T21:30, offset optional), or
+	// seconds since midnight of date=YYYY-MM-DD (default today). Seconds up to
+	// 48h address the night after the service day, as in GTFS.
+	date, departureTime := routing.ServiceTime(time.Now())
+	if dateParam := r.URL.Query().Get("date"); dateParam != "" {
+		parsed, err := routing.ParseDate(dateParam)
+		if err != nil {
+			http.Error(w, "Invalid date: must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		date = parsed
+	}
+	if timeParam := r.URL.Query().Get("time"); timeParam != "" && timeParam != "now" {
+		if parsed, err := strconv.Atoi(timeParam); err == nil {
+			if parsed < 0 || parsed >= 2*routing.SecondsPerDay {
+				http.Error(w, "Invalid time: seconds since midnight must be under 48h", http.StatusBadRequest)
+				return
+			}
 			departureTime = parsed
+		} else if parsed, err := routing.ParseDateTime(timeParam); err == nil {
+			date, departureTime = routing.ServiceTime(parsed)
+		} else {
+			http.Error(w, "Invalid time: must be now, an ISO-8601 datetime or seconds since midnight", http.StatusBadRequest)
+			return
 		}
 	}
-	
-	dayType := "weekday" // Default
-	if dayParam := r.URL.Query().Get("day"); dayParam != "" {
-		dayParam = strings.ToLower(dayParam)
-		// Normalize weekend variants to a special bucket we will fan out later
-		if dayParam == "weekend" {
-			dayType = "weekend"
-		} else if dayParam == "saturday" || dayParam == "sunday" {
-			dayType = dayParam
+
+	// arrive_by=true makes time the latest acceptable arrival instead of the departure
+	arriveBy := r.URL.Query().Get("arrive_by") == "true"
+
+	// Optional end of a departure window: return every journey leaving between time and until
+	windowEnd := -1
+	if untilParam := r.URL.Query().Get("until"); untilParam != "" {
+		parsed, err := strconv.Atoi(untilParam)
+		if err != nil {
+			var until time.Time
+			if until, err = routing.ParseDateTime(untilParam); err == nil {
+				parsed = routing.ServiceSeconds(date, until)
+			}
+		}
+		if err != nil || parsed < departureTime || parsed >= 2*routing.SecondsPerDay {
+			http.Error(w, "Invalid until: must be a datetime or seconds since midnight after time", http.StatusBadRequest)
+			return
+		}
+		if arriveBy {
+			http.Error(w, "until cannot be combined with arrive_by", http.StatusBadRequest)
+			return
 		}
+		windowEnd = parsed
 	}
 
-	if fromLat == 0 || toLat == 0 {
-		http.Error(w, "Missing source/destination coordinates", http.StatusBadRequest)
+	// Fare preferences: max_fare in MAD, optimize=fastest|cheapest
+	var opts routing.RouteOptions
+	if maxFareParam := r.URL.Query().Get("max_fare"); maxFareParam != "" {
+		parsed, err := strconv.ParseFloat(maxFareParam, 64)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid max_fare: must be a positive amount in MAD", http.StatusBadRequest)
+			return
+		}
+		opts.MaxFare = parsed
+	}
+	switch optimize := r.URL.Query().Get("optimize"); optimize {
+	case "", routing.OptimizeFastest, routing.OptimizeCheapest:
+		opts.Optimize = optimize
+	default:
+		http.Error(w, "Invalid optimize: must be fastest or cheapest", http.StatusBadRequest)
 		return
 	}
 
-	// 1. Find multiple source stops (within 1km)
-	// We need a helper for this. Using DB or In-Memory?
-	// The DB has geospatial index, use Repo.
-	// Repository signature is (minLat, minLon, maxLat, maxLon)
-	sources, err := h.Repo.GetStopsInViewport(r.Context(), fromLat-0.01, fromLon-0.01, fromLat+0.01, fromLon+0.01)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// wheelchair=true restricts the search to accessible stops, vehicles and footpaths
+	opts.Wheelchair = r.URL.Query().Get("wheelchair") == "true"
+
+	// modes=tram,busway limits the line types, exclude_lines=L7,L33 avoids lines
+	for _, mode := range splitList(r.URL.Query().Get("modes")) {
+		mode = strings.ToLower(mode)
+		if !slices.Contains(routing.LineTypes, mode) {
+			http.Error(w, "Invalid modes: must be among "+strings.Join(routing.LineTypes, ", "), http.StatusBadRequest)
+			return
+		}
+		opts.Modes = append(opts.Modes, mode)
 	}
-	
-	targets, err := h.Repo.GetStopsInViewport(r.Context(), toLat-0.01, toLon-0.01, toLat+0.01, toLon+0.01)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	opts.ExcludeLines = splitList(r.URL.Query().Get("exclude_lines"))
+
+	// max_transfers, walk_speed in m/s and max_walk_meters bound the search
+	if maxTransfersParam := r.URL.Query().Get("max_transfers"); maxTransfersParam != "" {
+		parsed, err := strconv.Atoi(maxTransfersParam)
+		if err != nil || parsed < 0 || parsed >= routing.MaxRoundsLimit {
+			http.Error(w, fmt.Sprintf("Invalid max_transfers: must be between 0 and %d", routing.MaxRoundsLimit-1), http.StatusBadRequest)
+			return
+		}
+		opts.MaxRides = parsed + 1
+	}
+	if walkSpeedParam := r.URL.Query().Get("walk_speed"); walkSpeedParam != "" {
+		parsed, err := strconv.ParseFloat(walkSpeedParam, 64)
+		if err != nil || parsed < 0.3 || parsed > 3 {
+			http.Error(w, "Invalid walk_speed: must be between 0.3 and 3 m/s", http.StatusBadRequest)
+			return
+		}
+		opts.WalkSpeed = parsed
+	}
+	if maxWalkParam := r.URL.Query().Get("max_walk_meters"); maxWalkParam != "" {
+		parsed, err := strconv.ParseFloat(maxWalkParam, 64)
+		if err != nil || parsed <= 0 || parsed > 5000 {
+			http.Error(w, "Invalid max_walk_meters: must be a distance up to 5000", http.StatusBadRequest)
+			return
+		}
+		opts.MaxWalk = parsed
 	}
 
-	// 2. Convert to Raptor Map
-	// Let's debug what stops we found
-	fmt.Printf("GetRoute: Found %d source stops, %d target stops, time=%d, day=%s\n", len(sources), len(targets), departureTime, dayType)
-	if len(sources) > 0 {
-		fmt.Printf("Source[0] DBID: %d\n", sources[0].ID)
+	// transfer_penalty in seconds, walk_reluctance and wait_reluctance rank
+	// the journeys by generalized cost, over DefaultCostModel
+	cost := routing.DefaultCostModel
+	costSet := false
+	if penaltyParam := r.URL.Query().Get("transfer_penalty"); penaltyParam != "" {
+		parsed, err := strconv.Atoi(penaltyParam)
+		if err != nil || parsed < 0 || parsed > 3600 {
+			http.Error(w, "Invalid transfer_penalty: must be seconds between 0 and 3600", http.StatusBadRequest)
+			return
+		}
+		cost.TransferPenalty, costSet = parsed, true
+	}
+	for _, weight := range []struct {
+		param string
+		value *float64
+	}{
+		{"walk_reluctance", &cost.WalkReluctance},
+		{"wait_reluctance", &cost.WaitReluctance},
+	} {
+		param := r.URL.Query().Get(weight.param)
+		if param == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(param, 64)
+		if err != nil || parsed < 0 || parsed > 10 {
+			http.Error(w, "Invalid "+weight.param+": must be a weight between 0 and 10", http.StatusBadRequest)
+			return
+		}
+		*weight.value, costSet = parsed, true
+	}
+	if costSet {
+		opts.Cost = &cost
 	}
 
-	sourceMap := h.Raptor.ConvertStopsToIDs(sources, 0) // 0 walk time for now
-	targetMapB := h.Raptor.ConvertStopsToIDs(targets, 0)
-	targetMap := make(map[routing.StopID]bool)
-	for k := range targetMapB { targetMap[k] = true }
+	// The service date picks the services from the calendar, holidays and
+	// Ramadan included. The older day=weekday|saturday|sunday|holiday|weekend
+	// overrides it.
+	dayType := date.Format(routing.DateLayout)
+	serviceDays := [][]routing.ServiceDay{raptor.ServiceDaysOn(date)}
+	if dayParam := r.URL.Query().Get("day"); dayParam != "" {
+		dayParam = strings.ToLower(dayParam)
+		// Weekend fans out to Saturday, then Sunday
+		switch dayParam {
+		case "weekend":
+			dayType = dayParam
+			serviceDays = [][]routing.ServiceDay{routing.ServiceDays("saturday"), routing.ServiceDays("sunday")}
+		case "weekday", "saturday", "sunday", routing.HolidayService:
+			dayType = dayParam
+			serviceDays = [][]routing.ServiceDay{routing.ServiceDays(dayParam)}
+		}
+	}
 
-	fmt.Printf("Mapped Source IDs: %v\n", sourceMap)
-	
-	if len(sourceMap) == 0 || len(targetMap) == 0 {
+	if fromLat == 0 || toLat == 0 {
+		http.Error(w, "Missing source/destination coordinates", http.StatusBadRequest)
+		return
+	}
+
+	// 1. Stops within walking distance of both ends, with the walk to each
+	from := raptor.PlaceFor("Origin", fromLat, fromLon, opts)
+	to := raptor.PlaceFor("Destination", toLat, toLon, opts)
+	fmt.Printf("GetRoute: Found %d source stops, %d target stops, time=%d, day=%s\n", len(from.Stops), len(to.Stops), departureTime, dayType)
+
+	if len(from.Stops) == 0 || len(to.Stops) == 0 {
 		http.Error(w, "No nearby stops found", http.StatusNotFound)
 		return
 	}
 	
 	// Try one or more service patterns depending on requested day.
-	dayOptions := []string{dayType}
-	if dayType == "weekend" {
-		dayOptions = []string{"saturday", "sunday"}
-	}
-
-	var journey *routing.Journey
-	for _, d := range dayOptions {
-		journey = h.Raptor.FindRoute(sourceMap, targetMap, departureTime, d)
-		if journey != nil {
+	// The search stops when the client goes away, at the server timeout or
+	// over its budget, with what it found so far.
+	var journeys []*routing.Journey
+	var searchErr error
+	for _, d := range serviceDays {
+		if arriveBy {
+			journeys, searchErr = raptor.FindRouteArriveBy(r.Context(), from, to, departureTime, d, opts)
+		} else if windowEnd >= 0 {
+			journeys, searchErr = raptor.FindRange(r.Context(), from, to, departureTime, windowEnd, d, opts)
+		} else {
+			journeys, searchErr = raptor.FindRoute(r.Context(), from, to, departureTime, d, opts)
+		}
+		if len(journeys) > 0 || searchErr != nil {
 			break
 		}
 	}
 
-	if journey == nil {
+	if searchErr != nil {
+		log.Printf("GetRoute: search stopped: %v", searchErr)
+		if r.Context().Err() != nil {
+			return // the client is gone, or the timeout middleware answers
+		}
+	}
+	if len(journeys) == 0 {
+		if searchErr != nil {
+			http.Error(w, "Route search stopped: "+searchErr.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		http.Error(w, "No route found", http.StatusNotFound)
 		return
 	}
-	
-	json.NewEncoder(w).Encode(journey)
+
+	// Alternatives are ordered by number of transfers (fewest first),
+	// or by departure time for window queries
+	for _, j := range journeys {
+		j.SetDate(date)
+	}
+	response := map[string]interface{}{
+		"date":     date.Format(routing.DateLayout),
+		"journeys": journeys,
+	}
+	if searchErr != nil {
+		// Over budget: the best journeys found, not necessarily the best there are
+		response["partial"] = true
+		response["warning"] = searchErr.Error()
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *TransportHandler) GetStops(w http.ResponseWriter, r *http.Request) {
@@ -184,3 +336,14 @@ func (h *TransportHandler) GetStopDetails(w http.ResponseWriter, r *http.Request
 	}
 	json.NewEncoder(w).Encode(response)
 }
+
+// splitList splits a comma-separated query parameter, dropping empty items.
+func splitList(param string) []string {
+	var items []string
+	for _, item := range strings.Split(param, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
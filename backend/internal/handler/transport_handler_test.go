@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/antigravity/morocco-transport/internal/routing"
+)
+
+// routeHandler serves a single bus line from stop 0 to stop 1, 1.1 km apart,
+// at 08:00 on weekdays.
+func routeHandler() *TransportHandler {
+	d := &routing.RaptorData{Transfers: map[routing.StopID][]routing.Transfer{}, DBIDToStopID: map[int]routing.StopID{}}
+	for i := range 2 {
+		d.Stops = append(d.Stops, routing.Stop{ID: routing.StopID(i), DBID: i + 1, Name: "S", Lat: 33.5 + float64(i)*0.01, Lon: -7.6})
+		d.DBIDToStopID[i+1] = routing.StopID(i)
+	}
+	trip := routing.Trip{ServiceId: "weekday", StopTimes: []routing.StopTime{{Arrival: 28800, Departure: 28800}, {Arrival: 29400, Departure: 29400}}}
+	d.Routes = []routing.Route{{
+		Stops: []routing.StopID{0, 1}, LineCode: "L1", LineType: "bus", FareID: -1, Price: 5,
+		Services: map[string]*routing.TripTable{"weekday": routing.NewTripTable([]routing.Trip{trip})},
+	}}
+	return NewTransportHandler(nil, routing.NewEngine(routing.NewRaptor(d), nil))
+}
+
+func TestGetRouteStopped(t *testing.T) {
+	h := routeHandler()
+	const query = "/route?from_lat=33.5&from_lon=-7.6&to_lat=33.51&to_lon=-7.6&time=28000&day=weekday"
+
+	rec := httptest.NewRecorder()
+	h.GetRoute(rec, httptest.NewRequest(http.MethodGet, query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want the journey", rec.Code, rec.Body)
+	}
+
+	// Over budget before any journey is found
+	budget := routing.DefaultBudget
+	routing.DefaultBudget.Stops = 1
+	t.Cleanup(func() { routing.DefaultBudget = budget })
+	rec = httptest.NewRecorder()
+	h.GetRoute(rec, httptest.NewRequest(http.MethodGet, query, nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "query budget exceeded") {
+		t.Fatalf("over budget: got %d %q, want 503", rec.Code, rec.Body)
+	}
+}
@@ -1,7 +1,5 @@
 package models
 
-import "time"
-
 type Line struct {
 	ID              int     `json:"id"`
 	Code            string  `json:"code"`
@@ -25,6 +23,6 @@ type Stop struct {
 }
 
 type Schedule struct {
-	DepartureTime time.Time `json:"departure_time"`
+	DepartureTime string    `json:"departure_time"` // HH:MM:SS of the service day, past 24:00:00 after midnight
 	Headsign      string    `json:"headsign"`
 }
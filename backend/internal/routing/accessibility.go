@@ -0,0 +1,34 @@
+package routing
+
+// stepFreeVehicles are the line types a wheelchair user can board unaided:
+// the low-floor trams and the busway with its level platforms. Buses, trains
+// and grands taxis need steps.
+var stepFreeVehicles = map[string]bool{
+	"tram":   true,
+	"busway": true,
+}
+
+// StepFreeVehicle reports whether vehicles of the line type are wheelchair accessible.
+func StepFreeVehicle(lineType string) bool {
+	return stepFreeVehicles[lineType]
+}
+
+// usesStop reports whether the search may board or alight at stop.
+func (o RouteOptions) usesStop(stop *Stop) bool {
+	return !o.Wheelchair || stop.Wheelchair
+}
+
+// usesTransfer reports whether the search may walk the footpath.
+func (o RouteOptions) usesTransfer(tr Transfer) bool {
+	return !o.Wheelchair || tr.StepFree
+}
+
+// transfer returns the footpath from one stop to another, if there is one.
+func (r *Raptor) transfer(from, to StopID) (Transfer, bool) {
+	for _, tr := range r.Data.Transfers[from] {
+		if tr.ToStop == to {
+			return tr, true
+		}
+	}
+	return Transfer{}, false
+}
@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindRouteWheelchair(t *testing.T) {
+	d := testNetwork()
+	for i := range d.Stops {
+		d.Stops[i].Wheelchair = i != int(stopE)
+	}
+	for i := range d.Routes {
+		d.Routes[i].Accessible = true
+	}
+	for s, trs := range d.Transfers {
+		for i := range trs {
+			trs[i].StepFree = true
+		}
+		d.Transfers[s] = trs
+	}
+	r := NewRaptor(d)
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+
+	// The change at E has no step-free boarding
+	for _, tc := range []struct {
+		name   string
+		find   func(RouteOptions) ([]*Journey, error)
+		all    int
+		direct string // the accessible journey, if any
+	}{
+		{"depart at 07:46:40", func(opts RouteOptions) ([]*Journey, error) {
+			return r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), opts)
+		}, 2, "08:00:00-08:30:00 x0: L1 A->D"},
+		{"arrive by 08:15", func(opts RouteOptions) ([]*Journey, error) {
+			return r.FindRouteArriveBy(context.Background(), from, to, 29700, ServiceDays("weekday"), opts)
+		}, 1, ""},
+		{"arrive by 09:00", func(opts RouteOptions) ([]*Journey, error) {
+			return r.FindRouteArriveBy(context.Background(), from, to, 32400, ServiceDays("weekday"), opts)
+		}, 1, "08:20:00-08:50:00 x0: L1 A->D"},
+	} {
+		if js := mustFind(t)(tc.find(RouteOptions{})); len(js) != tc.all {
+			t.Fatalf("%s: got %d journeys, want %d", tc.name, len(js), tc.all)
+		}
+		for _, opts := range []RouteOptions{{Wheelchair: true}, {Wheelchair: true, Optimize: OptimizeCheapest}} {
+			js := mustFind(t)(tc.find(opts))
+			ok := len(js) == 0 && tc.direct == ""
+			if len(js) == 1 {
+				ok = describe(js[0]) == tc.direct && js[0].Accessible
+			}
+			if !ok {
+				for _, j := range js {
+					t.Log(describe(j))
+				}
+				t.Fatalf("%s %+v: want only %q", tc.name, opts, tc.direct)
+			}
+		}
+	}
+
+	// Nor can the direct line be used once its vehicles have steps
+	d.Routes[0].Accessible = false
+	r = NewRaptor(d)
+	if js := mustFind(t)(r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), RouteOptions{Wheelchair: true})); len(js) != 0 {
+		t.Fatalf("got %d journeys without accessible vehicles, want none", len(js))
+	}
+}
@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// The benchmarks run a fixed set of queries on a fixed network about the
+// size of Casablanca's, so that runs on two trees compare with benchstat:
+//
+//	go test -run '^$' -bench . -count 10 ./internal/routing > new.txt
+//	benchstat old.txt new.txt
+
+var benchNetwork = sync.OnceValues(func() (*Raptor, [][2]Place) {
+	r := NewRaptor(randomNetwork(1, 3000, 400))
+	rng := rand.New(rand.NewSource(1))
+	queries := make([][2]Place, 200)
+	for i := range queries {
+		for j := range queries[i] {
+			queries[i][j] = at(map[StopID]int{StopID(rng.Intn(3000)): 0, StopID(rng.Intn(3000)): 300})
+		}
+	}
+	return r, queries
+})
+
+const benchDeparture = 8 * 3600
+
+func benchmarkQuery(b *testing.B, query func(r *Raptor, from, to Place) ([]*Journey, error)) {
+	r, queries := benchNetwork()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := queries[i%len(queries)]
+		if _, err := query(r, q[0], q[1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindRoute(b *testing.B) {
+	benchmarkQuery(b, func(r *Raptor, from, to Place) ([]*Journey, error) {
+		return r.FindRoute(context.Background(), from, to, benchDeparture, ServiceDays("weekday"), RouteOptions{})
+	})
+}
+
+func BenchmarkFindRouteArriveBy(b *testing.B) {
+	benchmarkQuery(b, func(r *Raptor, from, to Place) ([]*Journey, error) {
+		return r.FindRouteArriveBy(context.Background(), from, to, benchDeparture+3600, ServiceDays("weekday"), RouteOptions{})
+	})
+}
+
+func BenchmarkFindRange(b *testing.B) {
+	benchmarkQuery(b, func(r *Raptor, from, to Place) ([]*Journey, error) {
+		return r.FindRange(context.Background(), from, to, benchDeparture, benchDeparture+3600, ServiceDays("weekday"), RouteOptions{})
+	})
+}
+
+func BenchmarkFindRouteMC(b *testing.B) {
+	benchmarkQuery(b, func(r *Raptor, from, to Place) ([]*Journey, error) {
+		return r.FindRoute(context.Background(), from, to, benchDeparture, ServiceDays("weekday"), RouteOptions{Optimize: OptimizeCheapest})
+	})
+}
+
+func BenchmarkFindRouteCost(b *testing.B) {
+	model := &CostModel{TransferPenalty: 1200, WalkReluctance: 4, WaitReluctance: 1}
+	benchmarkQuery(b, func(r *Raptor, from, to Place) ([]*Journey, error) {
+		return r.FindRoute(context.Background(), from, to, benchDeparture, ServiceDays("weekday"), RouteOptions{Cost: model})
+	})
+}
+
+// BenchmarkFindRouteParallel runs queries from every P at once on one Raptor,
+// sharing its pools.
+func BenchmarkFindRouteParallel(b *testing.B) {
+	r, queries := benchNetwork()
+	b.ReportAllocs()
+	b.ResetTimer()
+	var next atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q := queries[int(next.Add(1))%len(queries)]
+			if _, err := r.FindRoute(context.Background(), q[0], q[1], benchDeparture, ServiceDays("weekday"), RouteOptions{}); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Budget bounds the work of one query, so that a pathological one cannot
+// hold a worker. A query over it stops and returns what it found so far.
+type Budget struct {
+	Stops int           // route stops scanned, 0 means DefaultBudget.Stops
+	Time  time.Duration // time spent, 0 means DefaultBudget.Time
+}
+
+// DefaultBudget is the budget of queries that do not set one. A range query
+// over an hour of the whole network scans a few million stops.
+var DefaultBudget = Budget{
+	Stops: 50_000_000,
+	Time:  10 * time.Second,
+}
+
+// ErrBudgetExceeded is returned, wrapped, with the partial result of a query
+// that went over its Budget.
+var ErrBudgetExceeded = errors.New("query budget exceeded")
+
+// guard stops a query once its context is done or its budget is spent.
+type guard struct {
+	ctx      context.Context
+	start    time.Time
+	deadline time.Time
+	stops    int // left to scan
+	scanned  int
+	err      error
+}
+
+func (o RouteOptions) guard(ctx context.Context) *guard {
+	b := o.Budget
+	if b.Stops <= 0 {
+		b.Stops = DefaultBudget.Stops
+	}
+	if b.Time <= 0 {
+		b.Time = DefaultBudget.Time
+	}
+	now := time.Now()
+	return &guard{ctx: ctx, start: now, deadline: now.Add(b.Time), stops: b.Stops}
+}
+
+// scan charges n stops about to be scanned and reports whether the query may
+// go on. It is cheap enough to call for every route.
+func (g *guard) scan(n int) bool {
+	if g.err != nil {
+		return false
+	}
+	g.stops -= n
+	g.scanned += n
+	if g.stops < 0 {
+		g.err = fmt.Errorf("%w: %d stops scanned", ErrBudgetExceeded, g.scanned)
+		return false
+	}
+	return true
+}
+
+// check reports whether the query may go on, looking at its context and the
+// clock. It is called between rounds.
+func (g *guard) check() bool {
+	if g.err != nil {
+		return false
+	}
+	if err := g.ctx.Err(); err != nil {
+		g.err = err
+		return false
+	}
+	if time.Now().After(g.deadline) {
+		g.err = fmt.Errorf("%w: stopped after %v", ErrBudgetExceeded, time.Since(g.start).Round(time.Millisecond))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// A stopped search returns what it found so far with the reason it stopped.
+func TestSearchStopped(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Enough for the first round only: the routes through the origin, or
+	// through the destination backwards, scan 6 stops
+	firstRound := RouteOptions{Budget: Budget{Stops: 6}}
+
+	for _, tc := range []struct {
+		name string
+		find func(context.Context, RouteOptions) ([]*Journey, error)
+		want []string // journeys of the first round
+	}{
+		{"FindRoute", func(ctx context.Context, opts RouteOptions) ([]*Journey, error) {
+			return r.FindRoute(ctx, from, to, 28000, ServiceDays("weekday"), opts)
+		}, []string{"08:00:00-08:30:00 x0: L1 A->D"}},
+		{"FindRoute cheapest", func(ctx context.Context, opts RouteOptions) ([]*Journey, error) {
+			opts.Optimize = OptimizeCheapest
+			return r.FindRoute(ctx, from, to, 28000, ServiceDays("weekday"), opts)
+		}, []string{"08:00:00-08:30:00 x0: L1 A->D"}},
+		{"FindRange", func(ctx context.Context, opts RouteOptions) ([]*Journey, error) {
+			return r.FindRange(ctx, from, to, 28800, 30000, ServiceDays("weekday"), opts)
+		}, []string{"08:20:00-08:50:00 x0: L1 A->D"}},
+		{"FindRouteArriveBy", func(ctx context.Context, opts RouteOptions) ([]*Journey, error) {
+			return r.FindRouteArriveBy(ctx, from, to, 9*3600, ServiceDays("weekday"), opts)
+		}, []string{"08:20:00-08:50:00 x0: L1 A->D"}},
+	} {
+		js, err := tc.find(cancelled, RouteOptions{})
+		if !errors.Is(err, context.Canceled) || len(js) != 0 {
+			t.Errorf("%s, cancelled: %d journeys, error %v; want none and the cancellation", tc.name, len(js), err)
+		}
+
+		js, err = tc.find(context.Background(), firstRound)
+		var got []string
+		for _, j := range js {
+			checkJourney(t, j)
+			got = append(got, describe(j))
+		}
+		if !errors.Is(err, ErrBudgetExceeded) || !slices.Equal(got, tc.want) {
+			t.Errorf("%s, over budget: %q, error %v; want %q and ErrBudgetExceeded", tc.name, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DateLayout is the format of calendar dates.
+const DateLayout = "2006-01-02"
+
+// Service is the calendar entry of a service ID, as in GTFS calendar.txt and
+// calendar_dates.txt: the weekdays it runs within a date range, and the dates
+// added to or removed from that pattern.
+type Service struct {
+	ID      string
+	Days    [7]bool         // indexed by time.Weekday
+	Start   time.Time       // first date, zero for no bound
+	End     time.Time       // last date, zero for no bound
+	Added   map[string]bool // DateLayout dates the service runs anyway
+	Removed map[string]bool // DateLayout dates the service does not run
+}
+
+// runs reports whether the service runs on a date, treated as weekday.
+func (s *Service) runs(date time.Time, weekday time.Weekday) bool {
+	day := date.Format(DateLayout)
+	if s.Removed[day] {
+		return false
+	}
+	if s.Added[day] {
+		return true
+	}
+	if !s.Start.IsZero() && date.Before(s.Start) {
+		return false
+	}
+	if !s.End.IsZero() && date.After(s.End) {
+		return false
+	}
+	return s.Days[weekday]
+}
+
+// Season is a period with its own timetables, such as Ramadan, when the tram
+// and buses start later, pause for iftar and run late into the night. While
+// it lasts, every service S is replaced by Name_S (ramadan_weekday), which
+// lines without a seasonal timetable run as S (see Calendar.FillSeasons).
+type Season struct {
+	Name  string
+	Start time.Time // first date
+	End   time.Time // last date
+}
+
+// Calendar tells which services run on a date.
+//
+// Public holidays run the timetable of a Sunday, which is what the operators'
+// "dimanches et jours fériés" timetables are, plus the "holiday" service for
+// lines that have one. The fixed civil holidays are known here; the Islamic
+// ones follow the moon sighting and come from Holidays.
+type Calendar struct {
+	Services map[string]*Service
+	Holidays map[string]string // DateLayout date -> name, besides the fixed ones
+	Seasons  []Season
+}
+
+// HolidayService runs on public holidays only.
+const HolidayService = "holiday"
+
+// DefaultCalendar returns the calendar of the day types the schedules table
+// uses, with no date bounds.
+func DefaultCalendar() *Calendar {
+	c := &Calendar{
+		Services: make(map[string]*Service),
+		Holidays: make(map[string]string),
+	}
+	weekdays := [7]bool{false, true, true, true, true, true, false}
+	c.Add(&Service{ID: "weekday", Days: weekdays})
+	c.Add(&Service{ID: "saturday", Days: [7]bool{time.Saturday: true}})
+	c.Add(&Service{ID: "sunday", Days: [7]bool{time.Sunday: true}})
+	c.Add(&Service{ID: HolidayService})
+	return c
+}
+
+// Add adds or replaces a service.
+func (c *Calendar) Add(s *Service) {
+	if s.Added == nil {
+		s.Added = make(map[string]bool)
+	}
+	if s.Removed == nil {
+		s.Removed = make(map[string]bool)
+	}
+	c.Services[s.ID] = s
+}
+
+// ServiceIDs returns the IDs of the services, sorted, followed by their
+// seasonal variants.
+func (c *Calendar) ServiceIDs() []string {
+	regular := c.regularIDs()
+	ids := append([]string(nil), regular...)
+	seen := make(map[string]bool)
+	for _, season := range c.Seasons {
+		if seen[season.Name] {
+			continue
+		}
+		seen[season.Name] = true
+		for _, id := range regular {
+			ids = append(ids, season.Name+"_"+id)
+		}
+	}
+	return ids
+}
+
+// regularIDs returns the IDs of the services, sorted, without their seasonal variants.
+func (c *Calendar) regularIDs() []string {
+	ids := make([]string, 0, len(c.Services))
+	for id := range c.Services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// season returns the season a date falls in, if any. The first one listed
+// wins where seasons overlap.
+func (c *Calendar) season(date time.Time) (*Season, bool) {
+	for i := range c.Seasons {
+		s := &c.Seasons[i]
+		if !date.Before(s.Start) && !date.After(s.End) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// FillSeasons lets a route without a seasonal timetable run its regular
+// trips during the season.
+func (c *Calendar) FillSeasons(route *Route) {
+	for _, season := range c.Seasons {
+		for id := range c.Services {
+			seasonal := season.Name + "_" + id
+			if route.Services[seasonal] == nil && route.Services[id] != nil {
+				route.Services[seasonal] = route.Services[id]
+			}
+		}
+	}
+}
+
+// Holiday returns the name of the public holiday on a date, if it is one.
+func (c *Calendar) Holiday(date time.Time) (string, bool) {
+	if name, ok := c.Holidays[date.Format(DateLayout)]; ok {
+		return name, true
+	}
+	name, ok := fixedHolidays[[2]int{int(date.Month()), date.Day()}]
+	if ok && date.Year() < fixedHolidaySince[name] {
+		return "", false
+	}
+	return name, ok
+}
+
+// Active returns the IDs of the services running on a date, sorted, in their
+// seasonal variant during a season.
+func (c *Calendar) Active(date time.Time) []string {
+	weekday := date.Weekday()
+	_, holiday := c.Holiday(date)
+	if holiday {
+		weekday = time.Sunday
+	}
+	var ids []string
+	for _, id := range c.regularIDs() {
+		s := c.Services[id]
+		if s.runs(date, weekday) || (holiday && id == HolidayService && !s.Removed[date.Format(DateLayout)]) {
+			ids = append(ids, id)
+		}
+	}
+	if season, ok := c.season(date); ok {
+		for i, id := range ids {
+			ids[i] = season.Name + "_" + id
+		}
+	}
+	return ids
+}
+
+// ServiceDays returns the service days a query on date can ride, like the
+// package-level ServiceDays but with the services the calendar runs on the
+// day before, the date itself and the day after.
+func (c *Calendar) ServiceDays(date time.Time) []ServiceDay {
+	var days []ServiceDay
+	for d := -1; d <= 1; d++ {
+		for _, id := range c.Active(date.AddDate(0, 0, d)) {
+			days = append(days, ServiceDay{ServiceID: id, Offset: d * SecondsPerDay})
+		}
+	}
+	return days
+}
+
+// ServiceDaysOn returns the service days of a query on date from the loaded
+// calendar, or the default one.
+func (r *Raptor) ServiceDaysOn(date time.Time) []ServiceDay {
+	if r.Data.Calendar == nil {
+		return DefaultCalendar().ServiceDays(date)
+	}
+	return r.Data.Calendar.ServiceDays(date)
+}
+
+// ParseDate parses a DateLayout date.
+func ParseDate(s string) (time.Time, error) {
+	date, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: want YYYY-MM-DD", s)
+	}
+	return date, nil
+}
+
+// fixedHolidays are the Moroccan public holidays on a fixed Gregorian date,
+// by [month, day].
+var fixedHolidays = map[[2]int]string{
+	{1, 1}:   "New Year's Day",
+	{1, 11}:  "Proclamation of Independence",
+	{1, 14}:  "Amazigh New Year",
+	{5, 1}:   "Labour Day",
+	{7, 30}:  "Throne Day",
+	{8, 14}:  "Oued Ed-Dahab Day",
+	{8, 20}:  "Revolution of the King and the People",
+	{8, 21}:  "Youth Day",
+	{10, 31}: "Unity Day",
+	{11, 6}:  "Green March",
+	{11, 18}: "Independence Day",
+}
+
+// fixedHolidaySince holds the first year of the holidays introduced recently.
+var fixedHolidaySince = map[string]int{
+	"Amazigh New Year": 2024,
+	"Unity Day":        2026,
+}
@@ -0,0 +1,98 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := ParseDate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCalendarActive(t *testing.T) {
+	c := DefaultCalendar()
+	c.Holidays["2026-03-20"] = "Eid al-Fitr"
+	c.Add(&Service{
+		ID:      "school",
+		Days:    [7]bool{1: true, 2: true, 3: true, 4: true, 5: true},
+		Start:   date(t, "2026-09-07"),
+		End:     date(t, "2027-06-30"),
+		Removed: map[string]bool{"2026-10-16": true},
+		Added:   map[string]bool{"2026-10-17": true},
+	})
+
+	for _, tc := range []struct {
+		date string
+		want []string
+	}{
+		{"2026-10-15", []string{"school", "weekday"}},  // a Thursday
+		{"2026-10-16", []string{"weekday"}},            // no school that Friday
+		{"2026-10-17", []string{"saturday", "school"}}, // but on Saturday
+		{"2026-10-18", []string{"sunday"}},
+		{"2026-09-04", []string{"weekday"}},           // before the school year
+		{"2026-11-18", []string{"holiday", "sunday"}}, // Independence Day, a Wednesday
+		{"2026-03-20", []string{"holiday", "sunday"}}, // Eid, from Holidays
+		{"2025-10-31", []string{"weekday"}},           // Unity Day is a holiday from 2026
+		{"2026-10-31", []string{"holiday", "sunday"}},
+	} {
+		if got := c.Active(date(t, tc.date)); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.date, got, tc.want)
+		}
+	}
+
+	if _, err := ParseDate("16/10/2026"); err == nil {
+		t.Error("ParseDate accepted 16/10/2026")
+	}
+}
+
+func TestCalendarServiceDays(t *testing.T) {
+	// A Monday: Sunday's late trips, then Monday's, then Tuesday's early ones
+	got := DefaultCalendar().ServiceDays(date(t, "2026-10-19"))
+	want := []ServiceDay{
+		{ServiceID: "sunday", Offset: -SecondsPerDay},
+		{ServiceID: "weekday", Offset: 0},
+		{ServiceID: "weekday", Offset: SecondsPerDay},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCalendarSeasons(t *testing.T) {
+	c := DefaultCalendar()
+	c.Seasons = []Season{{Name: "ramadan", Start: date(t, "2026-02-19"), End: date(t, "2026-03-19")}}
+
+	if got := c.ServiceIDs(); !slices.Equal(got, []string{
+		"holiday", "saturday", "sunday", "weekday",
+		"ramadan_holiday", "ramadan_saturday", "ramadan_sunday", "ramadan_weekday",
+	}) {
+		t.Errorf("service IDs %v", got)
+	}
+	for _, tc := range []struct {
+		date string
+		want []string
+	}{
+		{"2026-02-18", []string{"weekday"}},
+		{"2026-02-19", []string{"ramadan_weekday"}}, // first and last days included
+		{"2026-03-19", []string{"ramadan_weekday"}},
+		{"2026-03-21", []string{"saturday"}},
+	} {
+		if got := c.Active(date(t, tc.date)); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.date, got, tc.want)
+		}
+	}
+
+	// A line with a Ramadan timetable on Sundays only keeps its weekday trips
+	weekday, ramadanSunday := NewTripTable(nil), NewTripTable(nil)
+	r := Route{Services: map[string]*TripTable{"weekday": weekday, "ramadan_sunday": ramadanSunday}}
+	c.FillSeasons(&r)
+	if r.Services["ramadan_weekday"] != weekday || r.Services["ramadan_sunday"] != ramadanSunday || r.Services["ramadan_saturday"] != nil {
+		t.Errorf("filled seasonal services %v", r.Services)
+	}
+}
@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // the zone must not depend on the host's zoneinfo
+)
+
+// Casablanca is the time zone of the network. Morocco keeps GMT+1 all year
+// except during Ramadan, when it returns to GMT; the tz database carries those
+// switches, so wall-clock times are always taken through this zone.
+var Casablanca = mustLoadZone("Africa/Casablanca")
+
+func mustLoadZone(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// dateTimeLayouts are the ISO-8601 forms ParseDateTime accepts; the ones
+// without an offset are Casablanca wall-clock times.
+var dateTimeLayouts = []struct {
+	layout string
+	offset bool
+}{
+	{time.RFC3339, true},
+	{"2006-01-02T15:04Z07:00", true},
+	{"2006-01-02T15:04:05", false},
+	{"2006-01-02T15:04", false},
+	{"2006-01-02 15:04:05", false},
+	{"2006-01-02 15:04", false},
+}
+
+// ParseDateTime parses an ISO-8601 datetime such as 2026-03-02T21:30 or
+// 2026-03-02T21:30:00+01:00.
+func ParseDateTime(s string) (time.Time, error) {
+	for _, l := range dateTimeLayouts {
+		var t time.Time
+		var err error
+		if l.offset {
+			t, err = time.Parse(l.layout, s)
+		} else {
+			t, err = time.ParseInLocation(l.layout, s, Casablanca)
+		}
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid datetime %q: want ISO-8601, e.g. 2026-03-02T21:30", s)
+}
+
+// ServiceTime returns the date of t in Casablanca, as the calendar keeps it,
+// and the seconds since its midnight.
+func ServiceTime(t time.Time) (date time.Time, seconds int) {
+	local := t.In(Casablanca)
+	date = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	return date, ServiceSeconds(date, t)
+}
+
+// DateTime returns the instant at seconds since the midnight of a service
+// date, counted on the Casablanca wall clock.
+func DateTime(date time.Time, seconds int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, seconds, 0, Casablanca)
+}
+
+// SetDate fills in the journey's datetimes for a query on a service date.
+func (j *Journey) SetDate(date time.Time) {
+	j.DepartureAt = DateTime(date, j.departure)
+	j.ArrivalAt = DateTime(date, j.arrival)
+}
+
+// ServiceSeconds returns the wall-clock seconds of t in Casablanca since the
+// midnight of a service date, past 24h on the following days.
+func ServiceSeconds(date, t time.Time) int {
+	local := t.In(Casablanca)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Sub(date).Hours() / 24)
+	return days*SecondsPerDay + TimeToSeconds(local)
+}
@@ -0,0 +1,63 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateTime(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string // UTC, empty for an error
+	}{
+		{"2026-06-01T21:30", "2026-06-01T20:30:00Z"}, // GMT+1
+		{"2026-02-25T21:30", "2026-02-25T21:30:00Z"}, // Ramadan, back to GMT
+		{"2026-06-01 21:30:15", "2026-06-01T20:30:15Z"},
+		{"2026-06-01T21:30:00Z", "2026-06-01T21:30:00Z"}, // an offset wins over the zone
+		{"2026-06-02T00:30+01:00", "2026-06-01T23:30:00Z"},
+		{"2026-06-01", ""},
+		{"21:30", ""},
+	} {
+		got, err := ParseDateTime(tc.in)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("ParseDateTime(%q) = %v, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got.UTC().Format(time.RFC3339) != tc.want {
+			t.Errorf("ParseDateTime(%q) = %v, %v; want %s", tc.in, got.UTC(), err, tc.want)
+		}
+	}
+}
+
+func TestServiceTime(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		date    string
+		seconds int
+	}{
+		{"2026-06-01T21:30:00Z", "2026-06-01", 22*3600 + 1800},
+		{"2026-06-01T23:30:00Z", "2026-06-02", 1800}, // already past midnight in Casablanca
+		{"2026-02-25T23:30:00Z", "2026-02-25", 23*3600 + 1800},
+	} {
+		in, _ := time.Parse(time.RFC3339, tc.in)
+		date, seconds := ServiceTime(in)
+		if date.Format(DateLayout) != tc.date || seconds != tc.seconds {
+			t.Errorf("ServiceTime(%s) = %s %d, want %s %d", tc.in, date.Format(DateLayout), seconds, tc.date, tc.seconds)
+		}
+		if back := DateTime(date, seconds); !back.Equal(in) {
+			t.Errorf("DateTime of ServiceTime(%s) = %v", tc.in, back)
+		}
+	}
+
+	// Times past 24:00:00 fall on the next morning
+	day, _ := ParseDate("2026-06-01")
+	next := DateTime(day, SecondsPerDay+1800)
+	if got := next.Format("2006-01-02T15:04-07:00"); got != "2026-06-02T00:30+01:00" {
+		t.Errorf("DateTime past midnight = %s", got)
+	}
+	if got := ServiceSeconds(day, next); got != SecondsPerDay+1800 {
+		t.Errorf("ServiceSeconds past midnight = %d", got)
+	}
+}
@@ -0,0 +1,54 @@
+package routing
+
+import "math"
+
+// CostModel weighs the parts of a journey into a generalized cost, in
+// seconds of riding: an elderly rider may accept a longer ride to walk less
+// and change less often, a commuter in a hurry the opposite.
+//
+// FindRoute searches on arrival time and cost together, so that a journey
+// arriving later survives if it costs less. Arrive-by and range queries
+// search on time only and use the cost to order what they find.
+type CostModel struct {
+	TransferPenalty int     // seconds added for every change of vehicle
+	WalkReluctance  float64 // weight of a second walked
+	WaitReluctance  float64 // weight of a second waited for a vehicle
+}
+
+// DefaultCostModel is the cost model of RouteOptions without one. It is what
+// Journey.Cost reports unless the query asks for another.
+var DefaultCostModel = CostModel{
+	TransferPenalty: TransferCost,
+	WalkReluctance:  2,
+	WaitReluctance:  1,
+}
+
+func (o RouteOptions) costModel() *CostModel {
+	if o.Cost == nil {
+		return &DefaultCostModel
+	}
+	return o.Cost
+}
+
+func (c *CostModel) walk(seconds int) int {
+	return int(math.Round(c.WalkReluctance * float64(seconds)))
+}
+
+func (c *CostModel) wait(seconds int) int {
+	return int(math.Round(c.WaitReluctance * float64(seconds)))
+}
+
+// journeyCost adds up the cost of the legs of a journey and the waits before
+// them. There is none before the first vehicle: the rider leaves in time for it.
+func (c *CostModel) journeyCost(j *Journey) int {
+	cost := c.TransferPenalty * j.Transfers
+	for _, leg := range j.Legs {
+		cost += c.wait(leg.WaitTime)
+		if leg.Type == "walk" {
+			cost += c.walk(leg.Duration)
+		} else {
+			cost += leg.Duration
+		}
+	}
+	return cost
+}
@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestFindRouteWalkingOptions(t *testing.T) {
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+	find := func(r *Raptor, opts RouteOptions) []string {
+		var got []string
+		for _, j := range mustFind(t)(r.FindRoute(context.Background(), from, to, 28700, ServiceDays("weekday"), opts)) {
+			got = append(got, describe(j))
+		}
+		return got
+	}
+	direct := "08:00:00-08:30:00 x0: L1 A->D"
+
+	// The 100 m walk from B to E takes 100 s, or 400 s at 0.3 m/s: the
+	// change then makes the second L3 trip only
+	r := NewRaptor(testNetwork())
+	for _, tc := range []struct {
+		name string
+		opts RouteOptions
+		want []string
+	}{
+		{"default", RouteOptions{}, []string{direct, "08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D"}},
+		{"slow", RouteOptions{WalkSpeed: 0.3}, []string{direct, "08:00:00-08:12:40 x1: L2 A->B walk B->E L3 E->D"}},
+		{"within 50 m", RouteOptions{MaxWalk: 50}, []string{direct}},
+		{"direct only", RouteOptions{MaxRides: 1}, []string{direct}},
+	} {
+		if got := find(r, tc.opts); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// A curated minimum of 300 s for the change: a slower walker takes no
+	// longer, and the limit on walking is still on the 100 m
+	d := testNetwork()
+	for _, s := range []StopID{stopB, stopE} {
+		other := stopB + stopE - s
+		d.applyTransferRule(TransferRule{From: s, To: other, Type: TransferMinTime, MinTime: 300})
+	}
+	if tr := d.Transfers[stopB][0]; tr.TimeSeconds != 300 || tr.Meters != 100 || !tr.MinTime {
+		t.Fatalf("min_time footpath %+v, want 300 s over 100 m", tr)
+	}
+	r = NewRaptor(d)
+	change := "08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D"
+	for _, tc := range []struct {
+		name string
+		opts RouteOptions
+		want []string
+	}{
+		{"default", RouteOptions{}, []string{direct, change}},
+		{"slow", RouteOptions{WalkSpeed: 0.3}, []string{direct, change}},
+		{"within 250 m", RouteOptions{MaxWalk: 250}, []string{direct, change}},
+		{"within 50 m", RouteOptions{MaxWalk: 50}, []string{direct}},
+	} {
+		if got := find(r, tc.opts); !slices.Equal(got, tc.want) {
+			t.Errorf("min_time, %s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFindRouteCostModel(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+	direct := "08:00:00-08:30:00 x0: L1 A->D"
+	change := "08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D"
+
+	for _, tc := range []struct {
+		name  string
+		model *CostModel
+		want  []string
+		costs []int
+	}{
+		// 660 s: 300 riding, 100 walking, 200 waiting at E, 60 riding
+		{"hurry", &CostModel{WalkReluctance: 1, WaitReluctance: 1}, []string{change, direct}, []int{660, 1800}},
+		{"elderly", &CostModel{TransferPenalty: 1200, WalkReluctance: 4, WaitReluctance: 1}, []string{direct, change}, []int{1800, 2160}},
+	} {
+		js := mustFind(t)(r.FindRoute(context.Background(), from, to, 28700, ServiceDays("weekday"), RouteOptions{Cost: tc.model}))
+		if len(js) != len(tc.want) {
+			t.Fatalf("%s: got %d journeys, want %d", tc.name, len(js), len(tc.want))
+		}
+		for i, j := range js {
+			if describe(j) != tc.want[i] || j.Cost != tc.costs[i] {
+				t.Errorf("%s: journey %d is %s costing %d, want %s costing %d", tc.name, i, describe(j), j.Cost, tc.want[i], tc.costs[i])
+			}
+		}
+	}
+}
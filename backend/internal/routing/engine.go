@@ -0,0 +1,154 @@
+package routing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Engine serves queries from the current routing snapshot and swaps in a new
+// one when the data is reloaded. A query takes the snapshot once with Raptor
+// and runs on it to the end, so a reload never disturbs queries in flight.
+type Engine struct {
+	current atomic.Pointer[Raptor]
+	load    func(ctx context.Context) (*RaptorData, error)
+
+	mu        sync.Mutex // guards the fields below
+	reloading bool
+	pending   bool // a reload was asked for while one was running
+	status    ReloadStatus
+}
+
+// ReloadStatus describes the current snapshot and the last reload.
+type ReloadStatus struct {
+	Version   int       `json:"version"` // 1 for the snapshot loaded at startup
+	LoadedAt  time.Time `json:"loadedAt"`
+	Reloading bool      `json:"reloading"`
+	LastError string    `json:"lastError,omitempty"`
+
+	// Report is the LoadReport of the current data, if it came from the database
+	Report *LoadReport `json:"report,omitempty"`
+}
+
+// NewEngine serves r and rebuilds the data with load on reload.
+func NewEngine(r *Raptor, load func(ctx context.Context) (*RaptorData, error)) *Engine {
+	e := &Engine{load: load}
+	e.current.Store(r)
+	e.status = ReloadStatus{Version: 1, LoadedAt: time.Now(), Report: r.Data.Report}
+	return e
+}
+
+// Raptor returns the current snapshot.
+func (e *Engine) Raptor() *Raptor {
+	return e.current.Load()
+}
+
+// Status returns the state of the snapshot and of the reloads.
+func (e *Engine) Status() ReloadStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.status
+	s.Reloading = e.reloading
+	return s
+}
+
+// reloadTimeout bounds a reload, so that a stuck query cannot keep the
+// engine reloading, and every later reload waiting, for good.
+const reloadTimeout = 5 * time.Minute
+
+// Reload rebuilds the data in the background and swaps it in. If a reload is
+// already running, another one follows it, so that changes made during the
+// current load are picked up; it reports whether a new reload was started.
+func (e *Engine) Reload() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.reloading {
+		e.pending = true
+		return false
+	}
+	e.reloading = true
+	go e.run()
+	return true
+}
+
+func (e *Engine) run() {
+	for {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+		data, err := e.load(ctx)
+		cancel()
+		var next *Raptor
+		if err == nil {
+			next = NewRaptor(data)
+		}
+
+		e.mu.Lock()
+		if err != nil {
+			log.Println("Reload failed, keeping the current data:", err)
+			e.status.LastError = err.Error()
+		} else {
+			e.current.Store(next)
+			e.status.Version++
+			e.status.LoadedAt = time.Now()
+			e.status.LastError = ""
+			e.status.Report = data.Report
+			log.Printf("Reloaded routing data (version %d) in %v", e.status.Version, time.Since(start).Round(time.Millisecond))
+		}
+		if !e.pending {
+			e.reloading = false
+			e.mu.Unlock()
+			return
+		}
+		e.pending = false
+		e.mu.Unlock()
+	}
+}
+
+// ReloadChannel is the PostgreSQL notification channel that asks for a reload.
+const ReloadChannel = "routing_reload"
+
+// reloadQuiet is how long notifications must stop before a reload starts, so
+// that an import sending one per statement causes a single reload.
+const reloadQuiet = 5 * time.Second
+
+// ListenForReloads reloads the engine on notifications on ReloadChannel until
+// ctx is done, reconnecting if the connection drops.
+func (e *Engine) ListenForReloads(ctx context.Context, pool *pgxpool.Pool) {
+	timer := time.AfterFunc(time.Hour, func() { e.Reload() })
+	timer.Stop()
+	defer timer.Stop()
+
+	for ctx.Err() == nil {
+		if err := listen(ctx, pool, func() { timer.Reset(reloadQuiet) }); err != nil && ctx.Err() == nil {
+			log.Println("Reload listener:", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+			}
+		}
+	}
+}
+
+// listen holds one connection listening on ReloadChannel and calls notify
+// for every notification.
+func listen(ctx context.Context, pool *pgxpool.Pool, notify func()) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+ReloadChannel); err != nil {
+		return err
+	}
+	for {
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			return err
+		}
+		notify()
+	}
+}
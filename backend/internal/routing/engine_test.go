@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeLoad is an Engine load func that waits for each result to be sent.
+type fakeLoad struct {
+	calls   chan context.Context
+	results chan error // nil loads testNetwork
+}
+
+func newFakeLoad() *fakeLoad {
+	return &fakeLoad{calls: make(chan context.Context), results: make(chan error)}
+}
+
+func (f *fakeLoad) load(ctx context.Context) (*RaptorData, error) {
+	f.calls <- ctx
+	if err := <-f.results; err != nil {
+		return nil, err
+	}
+	return testNetwork(), nil
+}
+
+// finish waits for the next load to start and ends it with err.
+func (f *fakeLoad) finish(t *testing.T, err error) {
+	t.Helper()
+	select {
+	case ctx := <-f.calls:
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("reload without a deadline")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reload started")
+	}
+	f.results <- err
+}
+
+// waitIdle waits for the reloads of e to be over.
+func waitIdle(t *testing.T, e *Engine) ReloadStatus {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if s := e.Status(); !s.Reloading {
+			return s
+		}
+	}
+	t.Fatal("still reloading")
+	return ReloadStatus{}
+}
+
+func TestEngineReload(t *testing.T) {
+	f := newFakeLoad()
+	first := NewRaptor(testNetwork())
+	e := NewEngine(first, f.load)
+
+	// A failed load keeps the snapshot
+	if !e.Reload() {
+		t.Fatal("reload not started")
+	}
+	f.finish(t, errors.New("database down"))
+	if s := waitIdle(t, e); s.Version != 1 || s.LastError != "database down" || e.Raptor() != first {
+		t.Fatalf("after a failed reload: %+v, want version 1 and the error", s)
+	}
+
+	// A successful one swaps it
+	e.Reload()
+	f.finish(t, nil)
+	s := waitIdle(t, e)
+	second := e.Raptor()
+	if s.Version != 2 || s.LastError != "" || second == first {
+		t.Fatalf("after a reload: %+v, want version 2 and a new snapshot", s)
+	}
+
+	// Reloads asked for during one come down to a single one after it
+	e.Reload()
+	for range 3 {
+		if e.Reload() {
+			t.Fatal("second reload started while one is running")
+		}
+	}
+	f.finish(t, nil)
+	f.finish(t, nil)
+	if s := waitIdle(t, e); s.Version != 4 || e.Raptor() == second {
+		t.Fatalf("after coalesced reloads: %+v, want version 4", s)
+	}
+	select {
+	case <-f.calls:
+		t.Fatal("a third reload ran")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
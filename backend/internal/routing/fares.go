@@ -0,0 +1,85 @@
+package routing
+
+import "math"
+
+// Fares are tracked in centimes during the search so that comparisons are exact.
+
+// ticket is the transferable ticket a rider currently holds.
+type ticket struct {
+	fare  int // index into RaptorData.Fares, -1 when none
+	until int // latest boarding time it still covers
+}
+
+var noTicket = ticket{fare: -1}
+
+func cents(mad float64) int {
+	return int(math.Round(mad * 100))
+}
+
+func mad(cents int) float64 {
+	return float64(cents) / 100
+}
+
+// findFare returns the index of the fare rule for a line, or -1.
+// Rules with an operator take precedence over generic ones for the same line type.
+func (d *RaptorData) findFare(operatorID int, lineType string) int {
+	best := -1
+	for i, f := range d.Fares {
+		if f.LineType != lineType {
+			continue
+		}
+		if f.OperatorID == operatorID {
+			return i
+		}
+		if f.OperatorID == 0 && best < 0 {
+			best = i
+		}
+	}
+	return best
+}
+
+// transferable reports whether a ticket bought under fare rule from is valid on rule to.
+func (r *Raptor) transferable(from, to int) bool {
+	a, b := r.Data.Fares[from], r.Data.Fares[to]
+	return a.TransferAllowed && b.TransferAllowed && a.OperatorID == b.OperatorID
+}
+
+// board returns what boarding route rid at boardTime costs given the ticket
+// held, and the ticket held afterwards.
+func (r *Raptor) board(t ticket, rid RouteID, boardTime int) (int, ticket) {
+	route := &r.Data.Routes[rid]
+	if t.fare >= 0 && route.FareID >= 0 && boardTime <= t.until && r.transferable(t.fare, route.FareID) {
+		return 0, t
+	}
+
+	cost := cents(route.Price)
+	if route.FareID >= 0 && r.Data.Fares[route.FareID].TransferAllowed {
+		return cost, ticket{fare: route.FareID, until: boardTime + r.Data.Fares[route.FareID].TransferWindow}
+	}
+	return cost, noTicket
+}
+
+// ticketCovers reports whether ticket a makes every future boarding at most as
+// expensive as ticket b does.
+func (r *Raptor) ticketCovers(a, b ticket) bool {
+	if b.fare < 0 {
+		return true
+	}
+	return a.fare >= 0 && a.until >= b.until && r.transferable(a.fare, b.fare) && r.transferable(b.fare, a.fare)
+}
+
+// priceLegs sets the fare paid on each transit leg and returns the total in centimes.
+func (r *Raptor) priceLegs(legs []Leg) int {
+	total := 0
+	held := noTicket
+	for i := range legs {
+		if legs[i].Type != "transit" {
+			continue
+		}
+		var cost int
+		cost, held = r.board(held, legs[i].routeID, legs[i].start)
+		legs[i].Fare = mad(cost)
+		total += cost
+	}
+	return total
+}
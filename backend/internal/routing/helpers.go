@@ -1,13 +1,8 @@
 package routing
 
-import "github.com/antigravity/morocco-transport/internal/models"
+import "github.com/antigravity/morocco-transport/internal/streets"
 
-func (r *Raptor) ConvertStopsToIDs(stops []models.Stop, initialWalk int) map[StopID]int {
-	result := make(map[StopID]int)
-	for _, s := range stops {
-		if id, ok := r.Data.DBIDToStopID[s.ID]; ok {
-			result[id] = initialWalk
-		}
-	}
-	return result
+// DistanceMeters returns the great-circle distance between two points.
+func DistanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	return streets.DistanceMeters(lat1, lon1, lat2, lon2)
 }
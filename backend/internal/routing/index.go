@@ -0,0 +1,114 @@
+package routing
+
+// routeStop is one occurrence of a stop in the stop sequence of a route.
+type routeStop struct {
+	route RouteID
+	pos   int32
+}
+
+// buildIndexes precomputes the lookups used by every query. They are flat
+// arrays: the routes serving stop s are
+// stopRoutes[stopRoutesStart[s]:stopRoutesStart[s+1]], each with the position
+// of s in that route, and the footpaths arriving at s are laid out the same way.
+func (r *Raptor) buildIndexes() {
+	n := len(r.Data.Stops)
+
+	r.stopRoutesStart = make([]int32, n+1)
+	for _, route := range r.Data.Routes {
+		for _, s := range route.Stops {
+			r.stopRoutesStart[s+1]++
+		}
+	}
+	for s := 0; s < n; s++ {
+		r.stopRoutesStart[s+1] += r.stopRoutesStart[s]
+	}
+	r.stopRoutes = make([]routeStop, r.stopRoutesStart[n])
+	fill := append([]int32(nil), r.stopRoutesStart[:n]...)
+	for _, route := range r.Data.Routes {
+		for pos, s := range route.Stops {
+			r.stopRoutes[fill[s]] = routeStop{route: route.ID, pos: int32(pos)}
+			fill[s]++
+		}
+	}
+
+	r.inboundStart = make([]int32, n+1)
+	for _, transfers := range r.Data.Transfers {
+		for _, tr := range transfers {
+			r.inboundStart[tr.ToStop+1]++
+		}
+	}
+	for s := 0; s < n; s++ {
+		r.inboundStart[s+1] += r.inboundStart[s]
+	}
+	r.inbound = make([]Transfer, r.inboundStart[n])
+	fill = append(fill[:0], r.inboundStart[:n]...)
+	for from, transfers := range r.Data.Transfers {
+		for _, tr := range transfers {
+			inbound := tr
+			inbound.ToStop = from
+			r.inbound[fill[tr.ToStop]] = inbound
+			fill[tr.ToStop]++
+		}
+	}
+
+	if r.Data.Streets != nil {
+		r.snaps = snapStops(r.Data.Streets, r.Data.Stops)
+		r.stopsAtNode = stopsByNode(r.snaps)
+	}
+}
+
+// routesAt returns the routes serving stop s with the position of s in each.
+func (r *Raptor) routesAt(s StopID) []routeStop {
+	return r.stopRoutes[r.stopRoutesStart[s]:r.stopRoutesStart[s+1]]
+}
+
+// inboundTransfers returns the footpaths arriving at s, with ToStop holding
+// the stop they start from.
+func (r *Raptor) inboundTransfers(s StopID) []Transfer {
+	return r.inbound[r.inboundStart[s]:r.inboundStart[s+1]]
+}
+
+// routeQueue collects the routes to scan in a round, each with the position
+// the scan starts from.
+type routeQueue struct {
+	pos    []int32 // per route, -1 when not queued
+	routes []RouteID
+}
+
+func newRouteQueue(numRoutes int) *routeQueue {
+	q := &routeQueue{pos: make([]int32, numRoutes)}
+	for i := range q.pos {
+		q.pos[i] = -1
+	}
+	return q
+}
+
+// addEarliest queues the route, keeping the earliest position (forward scans).
+func (q *routeQueue) addEarliest(rs routeStop) {
+	switch p := q.pos[rs.route]; {
+	case p < 0:
+		q.pos[rs.route] = rs.pos
+		q.routes = append(q.routes, rs.route)
+	case rs.pos < p:
+		q.pos[rs.route] = rs.pos
+	}
+}
+
+// addLatest queues the route, keeping the latest position (backward scans).
+func (q *routeQueue) addLatest(rs routeStop) {
+	switch p := q.pos[rs.route]; {
+	case p < 0:
+		q.pos[rs.route] = rs.pos
+		q.routes = append(q.routes, rs.route)
+	case rs.pos > p:
+		q.pos[rs.route] = rs.pos
+	}
+}
+
+// reset empties the queue, touching only the queued routes.
+func (q *routeQueue) reset() {
+	for _, rid := range q.routes {
+		q.pos[rid] = -1
+	}
+	q.routes = q.routes[:0]
+}
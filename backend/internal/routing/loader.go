@@ -2,216 +2,588 @@ package routing
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/antigravity/morocco-transport/internal/streets"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Loader struct {
 	db *pgxpool.Pool
+
+	// Streets, if set, is used for the footpaths between stops instead of straight lines
+	Streets *streets.Graph
+
+	// Speeds estimate the travel time of segments without one in line_stops
+	Speeds Speeds
 }
 
 func NewLoader(db *pgxpool.Pool) *Loader {
-	return &Loader{db: db}
+	return &Loader{db: db, Speeds: DefaultSpeeds}
+}
+
+// LoadReport describes what a load read from the database and what it left out.
+type LoadReport struct {
+	Duration         time.Duration `json:"duration"`
+	Fares            int           `json:"fares"`
+	Services         int           `json:"services"`
+	Holidays         int           `json:"holidays"`
+	Seasons          int           `json:"seasons"`
+	Stops            int           `json:"stops"`
+	Patterns         int           `json:"patterns"` // line directions with a route
+	Routes           int           `json:"routes"`   // stop sequences, several per pattern for short turns and branches
+	Trips            int           `json:"trips"`
+	Frequencies      int           `json:"frequencies"`
+	Transfers        int           `json:"transfers"`
+	CuratedTransfers int           `json:"curatedTransfers"`
+
+	Skipped []SkippedLine `json:"skipped,omitempty"`
+
+	// Rows left out of the routes that were loaded
+	UnknownStopRows    int            `json:"unknownStopRows,omitempty"`    // line_stops and schedules rows at stops not loaded
+	BadTimeRows        int            `json:"badTimeRows,omitempty"`        // schedules rows whose time does not parse
+	UnknownServiceRows map[string]int `json:"unknownServiceRows,omitempty"` // schedules rows by day type the calendar does not know
+}
+
+// SkippedLine is a direction of a line that has no route, and why.
+type SkippedLine struct {
+	LineID    int    `json:"lineId"`
+	Code      string `json:"code,omitempty"`
+	Direction int    `json:"direction"`
+	Reason    string `json:"reason"`
+}
+
+// Reasons for skipping a line direction.
+const (
+	SkipUnknownLine = "line not found"
+	SkipNoStops     = "no stops in line_stops"
+	SkipFewStops    = "fewer than 2 known stops"
+	SkipNoSchedules = "no schedules"
+)
+
+// pattern is the stop sequence of a line direction in line_stops. Its trips
+// become a route per stop sequence they run (see splitVariants), all with the
+// line of the pattern.
+type pattern struct {
+	lineID, direction int
+	dbStops           []int
+	hops              []int // seconds from the previous stop
+	route             Route // the line and the full stop sequence
+	skipped           bool
+
+	variants []*Route
+	byStops  map[string]*Route
+}
+
+// variant returns the route of a stop sequence of the pattern, creating it.
+func (p *pattern) variant(v *variant, data *RaptorData) *Route {
+	if r, ok := p.byStops[v.key]; ok {
+		return r
+	}
+	r := p.route
+	r.Stops = make([]StopID, len(v.stops))
+	for i, sid := range v.stops {
+		r.Stops[i] = data.DBIDToStopID[sid]
+	}
+	r.Services = make(map[string]*TripTable)
+	p.variants = append(p.variants, &r)
+	p.byStops[v.key] = &r
+	return &r
 }
 
+type patternKey struct{ lineID, direction int }
+
+// LoadData reads the network with a fixed number of queries, all in one
+// read-only transaction so that an import running meanwhile is seen whole or
+// not at all. The returned data carries a LoadReport.
 func (l *Loader) LoadData(ctx context.Context) (*RaptorData, error) {
 	log.Println("Loading RAPTOR data from database...")
 	start := time.Now()
 
+	tx, err := l.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
 	data := &RaptorData{
 		Transfers:    make(map[StopID][]Transfer),
 		DBIDToStopID: make(map[int]StopID),
+		Streets:      l.Streets,
+	}
+	report := &LoadReport{UnknownServiceRows: make(map[string]int)}
+
+	// 0. Load Fares (single tickets only, the others are passes)
+	if err := l.loadFares(ctx, tx, data); err != nil {
+		return nil, fmt.Errorf("loading fares: %w", err)
+	}
+
+	// 0b. Load the Calendar (service dates and public holidays)
+	if data.Calendar, err = l.loadCalendar(ctx, tx); err != nil {
+		return nil, fmt.Errorf("loading calendar: %w", err)
 	}
 
 	// 1. Load All Stops
-	// Map DB ID -> Raptor ID
-	stopMap := make(map[int]StopID)
+	if err := l.loadStops(ctx, tx, data); err != nil {
+		return nil, fmt.Errorf("loading stops: %w", err)
+	}
 
-	rows, err := l.db.Query(ctx, "SELECT id, code, name_fr, ST_X(location::geometry), ST_Y(location::geometry) FROM stops")
+	// 2. Load Routes and Trips: a route per stop sequence of each (line_id, direction)
+	patterns, err := l.loadPatterns(ctx, tx, data, report)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("loading line stops: %w", err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var s Stop
-		var dbID int
-		if err := rows.Scan(&dbID, &s.Code, &s.Name, &s.Lon, &s.Lat); err != nil {
-			return nil, err
+	if err := l.loadSchedules(ctx, tx, data, patterns, report); err != nil {
+		return nil, fmt.Errorf("loading schedules: %w", err)
+	}
+	for _, p := range patterns {
+		if p.skipped {
+			continue
+		}
+		if len(p.variants) == 0 {
+			report.skip(p, SkipNoSchedules)
+			continue
 		}
-		s.DBID = dbID
+		report.Patterns++
+		for _, route := range p.variants {
+			data.Calendar.FillSeasons(route)
+			route.ID = RouteID(len(data.Routes))
+			data.Routes = append(data.Routes, *route)
+		}
+	}
+
+	// 3. Generate Transfers
+	if report.Transfers, err = l.generateTransfers(ctx, tx, data); err != nil {
+		return nil, fmt.Errorf("generating transfers: %w", err)
+	}
+
+	// 4. Merge the curated transfers table over the generated footpaths
+	if report.CuratedTransfers, err = l.loadTransferRules(ctx, tx, data); err != nil {
+		return nil, fmt.Errorf("loading transfers: %w", err)
+	}
+
+	report.Duration = time.Since(start)
+	report.Fares = len(data.Fares)
+	report.Services = len(data.Calendar.Services)
+	report.Holidays = len(data.Calendar.Holidays)
+	report.Seasons = len(data.Calendar.Seasons)
+	report.Stops = len(data.Stops)
+	report.Routes = len(data.Routes)
+	data.Report = report
+	report.log()
+	return data, nil
+}
+
+func (r *LoadReport) skip(p *pattern, reason string) {
+	p.skipped = true
+	r.Skipped = append(r.Skipped, SkippedLine{LineID: p.lineID, Code: p.route.LineCode, Direction: p.direction, Reason: reason})
+}
+
+func (r *LoadReport) log() {
+	log.Printf("Loaded %d stops, %d routes on %d line directions (%d trips, %d frequencies), %d fare rules, %d services, %d transfers (%d curated)",
+		r.Stops, r.Routes, r.Patterns, r.Trips, r.Frequencies, r.Fares, r.Services, r.Transfers, r.CuratedTransfers)
+	for _, s := range r.Skipped {
+		log.Printf("Skipped line %d %q direction %d: %s", s.LineID, s.Code, s.Direction, s.Reason)
+	}
+	if r.UnknownStopRows > 0 || r.BadTimeRows > 0 || len(r.UnknownServiceRows) > 0 {
+		log.Printf("Ignored rows: %d at unknown stops, %d with bad times, by unknown day type %v",
+			r.UnknownStopRows, r.BadTimeRows, r.UnknownServiceRows)
+	}
+	log.Printf("RAPTOR Data Load complete in %s", r.Duration)
+}
+
+func (l *Loader) loadFares(ctx context.Context, tx pgx.Tx, data *RaptorData) error {
+	rows, err := tx.Query(ctx, `
+		SELECT id, COALESCE(operator_id, 0), COALESCE(line_type, ''), fare_mad::float8,
+		       COALESCE(transfer_allowed, false), COALESCE(transfer_time_minutes, 0)
+		FROM fares
+		WHERE fare_type = 'single'
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	var f FareRule
+	var transferMinutes int
+	_, err = pgx.ForEachRow(rows, []any{&f.ID, &f.OperatorID, &f.LineType, &f.Price, &f.TransferAllowed, &transferMinutes}, func() error {
+		f.TransferWindow = transferMinutes * 60
+		data.Fares = append(data.Fares, f)
+		return nil
+	})
+	return err
+}
+
+func (l *Loader) loadStops(ctx context.Context, tx pgx.Tx, data *RaptorData) error {
+	rows, err := tx.Query(ctx, "SELECT id, code, name_fr, ST_X(location::geometry), ST_Y(location::geometry), COALESCE(wheelchair_accessible, false) FROM stops")
+	if err != nil {
+		return err
+	}
+	var s Stop
+	_, err = pgx.ForEachRow(rows, []any{&s.DBID, &s.Code, &s.Name, &s.Lon, &s.Lat, &s.Wheelchair}, func() error {
 		s.ID = StopID(len(data.Stops))
-		stopMap[dbID] = s.ID
-		data.DBIDToStopID[dbID] = s.ID
+		data.DBIDToStopID[s.DBID] = s.ID
 		data.Stops = append(data.Stops, s)
+		return nil
+	})
+	return err
+}
+
+// loadPatterns reads the lines and their stop sequences, in a query each.
+// Line directions that cannot become a route are reported and marked skipped.
+func (l *Loader) loadPatterns(ctx context.Context, tx pgx.Tx, data *RaptorData, report *LoadReport) ([]*pattern, error) {
+	lines := make(map[int]Route)
+	rows, err := tx.Query(ctx, "SELECT id, code, line_type, COALESCE(color, '#000000'), COALESCE(operator_id, 0) FROM lines")
+	if err != nil {
+		return nil, err
+	}
+	var line Route
+	_, err = pgx.ForEachRow(rows, []any{&line.LineID, &line.LineCode, &line.LineType, &line.LineColor, &line.OperatorID}, func() error {
+		lines[line.LineID] = line
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("Loaded %d stops", len(data.Stops))
 
-	// 2. Load Routes and Trips
-	// We group by (line_id, direction) to form unique physical paths (Patterns)
-	// Theoretically, RAPTOR routes are unique stop sequences.
-	
-	// Query: distinct line_id, direction
-	patternRows, err := l.db.Query(ctx, "SELECT DISTINCT line_id, direction FROM line_stops")
+	rows, err = tx.Query(ctx, `
+		SELECT line_id, direction, stop_id, travel_time_seconds, distance_meters FROM line_stops
+		ORDER BY line_id, direction, stop_sequence
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var all []*pattern
+	var p *pattern
+	pending := 0 // time of segments leading to stops we do not know
+	var lineID, direction, sid int
+	var travelTime, distance *int
+	_, err = pgx.ForEachRow(rows, []any{&lineID, &direction, &sid, &travelTime, &distance}, func() error {
+		if p == nil || p.lineID != lineID || p.direction != direction {
+			p = &pattern{lineID: lineID, direction: direction, route: lines[lineID]}
+			all = append(all, p)
+			pending = 0
+		}
+		rid, ok := data.DBIDToStopID[sid]
+		if !ok {
+			report.UnknownStopRows++
+		}
+		if stops := p.route.Stops; len(stops) > 0 {
+			var next *Stop
+			if ok {
+				next = &data.Stops[rid]
+			}
+			pending += l.Speeds.segmentTime(p.route.LineType, travelTime, distance, &data.Stops[stops[len(stops)-1]], next)
+		}
+		if ok {
+			p.route.Stops = append(p.route.Stops, rid)
+			p.dbStops = append(p.dbStops, sid)
+			p.hops = append(p.hops, pending)
+			pending = 0
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer patternRows.Close()
 
-	patterns := [][2]int{} // [line_id, direction]
-	for patternRows.Next() {
-		var lid, dir int
-		patternRows.Scan(&lid, &dir)
-		patterns = append(patterns, [2]int{lid, dir})
+	for _, p := range all {
+		if _, ok := lines[p.lineID]; !ok {
+			report.skip(p, SkipUnknownLine)
+			continue
+		}
+		if len(p.route.Stops) < 2 {
+			report.skip(p, SkipFewStops)
+			continue
+		}
+		r := &p.route
+		r.FareID = data.findFare(r.OperatorID, r.LineType)
+		r.Accessible = StepFreeVehicle(r.LineType)
+		r.Price = 5.0 // Default base price for lines without a fare rule (trains, taxis)
+		if r.FareID >= 0 {
+			r.Price = data.Fares[r.FareID].Price
+		}
+		p.byStops = make(map[string]*Route)
 	}
+	return all, nil
+}
 
+// loadSchedules reads the whole schedules table in one query, sorted so that
+// the rows of each pattern and service come together, and builds the trips of
+// every group as it ends.
+func (l *Loader) loadSchedules(ctx context.Context, tx pgx.Tx, data *RaptorData, patterns []*pattern, report *LoadReport) error {
+	byKey := make(map[patternKey]*pattern, len(patterns))
 	for _, p := range patterns {
-		lineID, dirID := p[0], p[1]
+		byKey[patternKey{p.lineID, p.direction}] = p
+	}
+	services := make(map[string]bool)
+	for _, id := range data.Calendar.ServiceIDs() {
+		services[id] = true
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT line_id, direction, day_type, stop_id, departure_time::text, COALESCE(trip_key, ''), COALESCE(headway_secs, 0)
+		FROM schedules
+		ORDER BY line_id, direction, day_type, departure_time, id
+	`)
+	if err != nil {
+		return err
+	}
+
+	var group []scheduleRow
+	var groupKey patternKey
+	var groupService string
+	flush := func() {
+		if p := byKey[groupKey]; p != nil && !p.skipped && len(group) > 0 {
+			for _, v := range splitVariants(p.dbStops, p.hops, group) {
+				if tt := scheduledTrips(v.stops, v.hops, v.rows, groupService); tt != nil {
+					p.variant(v, data).Services[groupService] = tt
+					report.Trips += len(tt.Trips)
+					report.Frequencies += len(tt.Frequencies)
+				}
+			}
+		}
+		group = group[:0]
+	}
 
-		// Get Line Info
-		var lineCode, lineType, lineColor string
-		err := l.db.QueryRow(ctx, "SELECT code, line_type, COALESCE(color, '#000000') FROM lines WHERE id=$1", lineID).Scan(&lineCode, &lineType, &lineColor)
+	var key patternKey
+	var dayType, t string
+	var row scheduleRow
+	_, err = pgx.ForEachRow(rows, []any{&key.lineID, &key.direction, &dayType, &row.stopDBID, &t, &row.tripKey, &row.headway}, func() error {
+		if key != groupKey || dayType != groupService {
+			flush()
+			groupKey, groupService = key, dayType
+		}
+		p := byKey[key]
+		if p == nil {
+			// Scheduled but not in line_stops: report it once
+			p = &pattern{lineID: key.lineID, direction: key.direction}
+			byKey[key] = p
+			report.skip(p, SkipNoStops)
+		}
+		if p.skipped {
+			return nil
+		}
+		if !services[dayType] {
+			report.UnknownServiceRows[dayType]++
+			return nil
+		}
+		if _, ok := data.DBIDToStopID[row.stopDBID]; !ok {
+			report.UnknownStopRows++
+			return nil
+		}
+		secs, err := ParseServiceTime(t)
 		if err != nil {
-			log.Println("Skipping line", lineID, err)
-			continue
+			report.BadTimeRows++
+			return nil
 		}
+		row.secs = secs
+		group = append(group, row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	flush()
+	return nil
+}
+
+// optionalTables are the tables a database may lack, being newer than the
+// network tables.
+var optionalTables = []string{"calendar", "calendar_dates", "seasons", "public_holidays"}
+
+// loadCalendar reads the calendar tables over DefaultCalendar. They are
+// optional: without them the day types run every week and only the fixed
+// public holidays are known.
+func (l *Loader) loadCalendar(ctx context.Context, tx pgx.Tx) (*Calendar, error) {
+	cal := DefaultCalendar()
 
-		// Get Ordered Stops
-		stopRows, err := l.db.Query(ctx, "SELECT stop_id FROM line_stops WHERE line_id=$1 AND direction=$2 ORDER BY stop_sequence", lineID, dirID)
+	// A failed query would abort the transaction, so look for the tables first
+	rows, err := tx.Query(ctx, "SELECT t FROM unnest($1::text[]) AS t WHERE to_regclass(t) IS NOT NULL", optionalTables)
+	if err != nil {
+		return nil, err
+	}
+	present, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	has := make(map[string]bool)
+	for _, t := range present {
+		has[t] = true
+	}
+	if !has["calendar"] {
+		log.Println("No calendar table, using the default day types")
+	} else {
+		rows, err := tx.Query(ctx, `
+			SELECT service_id, monday, tuesday, wednesday, thursday, friday, saturday, sunday,
+			       start_date::text, end_date::text
+			FROM calendar
+		`)
 		if err != nil {
 			return nil, err
 		}
-		var stopIDs []StopID
-		var dbStopIDs []int
-		for stopRows.Next() {
-			var sid int
-			stopRows.Scan(&sid)
-			if rid, ok := stopMap[sid]; ok {
-				stopIDs = append(stopIDs, rid)
-				dbStopIDs = append(dbStopIDs, sid)
+		var id string
+		var days [7]bool
+		var start, end *string
+		_, err = pgx.ForEachRow(rows, []any{&id, &days[time.Monday], &days[time.Tuesday], &days[time.Wednesday], &days[time.Thursday],
+			&days[time.Friday], &days[time.Saturday], &days[time.Sunday], &start, &end}, func() error {
+			s := &Service{ID: id, Days: days}
+			var err error
+			if start != nil {
+				if s.Start, err = ParseDate(*start); err != nil {
+					return fmt.Errorf("service %s: %w", id, err)
+				}
+			}
+			if end != nil {
+				if s.End, err = ParseDate(*end); err != nil {
+					return fmt.Errorf("service %s: %w", id, err)
+				}
 			}
+			cal.Add(s)
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
-		stopRows.Close()
+	}
 
-		if len(stopIDs) < 2 {
-			continue
+	if has["calendar_dates"] {
+		rows, err := tx.Query(ctx, "SELECT service_id, date::text, exception_type FROM calendar_dates")
+		if err != nil {
+			return nil, err
 		}
-
-		// Create Route
-		route := Route{
-			ID:        RouteID(len(data.Routes)),
-			Stops:     stopIDs,
-			LineID:    lineID,
-			LineCode:  lineCode,
-			LineType:  lineType,
-			LineColor: lineColor,
-			Price:     5.0, // Default base price, should load from fares
-		}
-		if lineType == "tram" || lineType == "busway" {
-			route.Price = 8.0 // Simplified for now
-		}
-
-		// Load Schedules (Trips)
-		// We fetch all departure times for the FIRST stop of this pattern
-		// Then we extrapolate the rest based on generic travel times if we don't have exact times for every stop.
-		// However, our `schedules` table stores `stop_id`. 
-		// Ideally we grab all schedules for this line/direction.
-		
-		// For simplicity/speed in this demo:
-		// fetch distinct days first
-		for _, dayType := range []string{"weekday", "saturday", "sunday"} {
-			// Find trips for this day. 
-			// We group by departure_time at the first stop to define a Trip.
-			
-			firstStopDBID := dbStopIDs[0]
-			tripRows, err := l.db.Query(ctx, `
-				SELECT departure_time FROM schedules 
-				WHERE line_id=$1 AND direction=$2 AND stop_id=$3 AND day_type=$4
-				ORDER BY departure_time
-			`, lineID, dirID, firstStopDBID, dayType)
-			if err != nil {
-				continue
+		var serviceID, date string
+		var exception int
+		_, err = pgx.ForEachRow(rows, []any{&serviceID, &date, &exception}, func() error {
+			s, ok := cal.Services[serviceID]
+			if !ok {
+				cal.Add(&Service{ID: serviceID})
+				s = cal.Services[serviceID]
 			}
-			
-			var startTimes []string
-			for tripRows.Next() {
-				var t string
-				tripRows.Scan(&t)
-				startTimes = append(startTimes, t)
+			switch exception {
+			case 1:
+				s.Added[date] = true
+			case 2:
+				s.Removed[date] = true
 			}
-			tripRows.Close()
-
-			for _, st := range startTimes {
-				trip := Trip{
-					ID:        TripID(len(route.Trips)), // Local ID within route? No, usually global needed? No, RAPTOR uses Route->Trip structure
-					ServiceId: dayType,
-					StopTimes: make([]StopTime, len(stopIDs)),
-				}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-				// Calculate times
-				// Simple logic: Assume 3 minutes? minutes between stops for Bus, 2 for Tram
-				// Better: Use `estimate_travel_time` or distance based.
-				// For now: 15km/h avg speed -> distance between stops.
-				// Let's use a fixed offset for robustness now: 3 mins per stop
-				
-				startTime, _ := time.Parse("15:04:05", st)
-				startSecs := TimeToSeconds(startTime)
-
-				currentSecs := startSecs
-				for i := range stopIDs {
-					trip.StopTimes[i] = StopTime{
-						Arrival:   currentSecs,
-						Departure: currentSecs,
-					}
-					// Add travel time to next stop
-					currentSecs += 180 // 3 minutes
-				}
-				route.Trips = append(route.Trips, trip)
+	if has["seasons"] {
+		rows, err := tx.Query(ctx, "SELECT name, start_date::text, end_date::text FROM seasons ORDER BY start_date")
+		if err != nil {
+			return nil, err
+		}
+		var name, start, end string
+		_, err = pgx.ForEachRow(rows, []any{&name, &start, &end}, func() error {
+			season := Season{Name: name}
+			var err error
+			if season.Start, err = ParseDate(start); err != nil {
+				return fmt.Errorf("season %s: %w", name, err)
 			}
+			if season.End, err = ParseDate(end); err != nil {
+				return fmt.Errorf("season %s: %w", name, err)
+			}
+			cal.Seasons = append(cal.Seasons, season)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if has["public_holidays"] {
+		rows, err := tx.Query(ctx, "SELECT date::text, name FROM public_holidays")
+		if err != nil {
+			return nil, err
+		}
+		var date, name string
+		_, err = pgx.ForEachRow(rows, []any{&date, &name}, func() error {
+			cal.Holidays[date] = name
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	return cal, nil
+}
 
-		data.Routes = append(data.Routes, route)
+// generateTransfers adds the footpaths between stops up to 300 m apart: along
+// the streets when a network is loaded, as the crow flies otherwise. It
+// returns how many it added.
+func (l *Loader) generateTransfers(ctx context.Context, tx pgx.Tx, data *RaptorData) (int, error) {
+	if data.Streets != nil {
+		log.Println("Generating transfers along the street network...")
+		return data.StreetTransfers(300), nil
 	}
-	log.Printf("Loaded %d routes", len(data.Routes))
 
-	// 3. Generate Transfers
-	// Simple euclidean distance < 300m (approx 0.003 degrees? No, need Haversine or PostGIS)
-	// We can use PostGIS to fetch pairs quickly!
-	
+	// PostGIS finds the pairs within 300 m with the spatial index
 	log.Println("Generating transfers...")
-	tRows, err := l.db.Query(ctx, `
-		SELECT s1.id, s2.id, ST_Distance(s1.location::geography, s2.location::geography) 
+	rows, err := tx.Query(ctx, `
+		SELECT s1.id, s2.id, ST_Distance(s1.location::geography, s2.location::geography)
 		FROM stops s1
 		JOIN stops s2 ON ST_DWithin(s1.location::geography, s2.location::geography, 300)
 		WHERE s1.id != s2.id
 	`)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer tRows.Close()
 
 	transferCount := 0
-	for tRows.Next() {
-		var id1, id2 int
-		var dist float64
-		tRows.Scan(&id1, &id2, &dist)
-
-		if rid1, ok := stopMap[id1]; ok {
-			if rid2, ok := stopMap[id2]; ok {
-				// Assume 1m/s walking speed
-				walkTime := int(dist) // seconds
-				data.Transfers[rid1] = append(data.Transfers[rid1], Transfer{
-					ToStop:      rid2,
-					TimeSeconds: walkTime,
-				})
-				transferCount++
-			}
+	var id1, id2 int
+	var dist float64
+	_, err = pgx.ForEachRow(rows, []any{&id1, &id2, &dist}, func() error {
+		rid1, ok1 := data.DBIDToStopID[id1]
+		rid2, ok2 := data.DBIDToStopID[id2]
+		if !ok1 || !ok2 {
+			return nil
 		}
-	}
-	log.Printf("Generated %d transfers", transferCount)
+		// Assume 1m/s walking speed; a footpath is step-free when both of its stops are accessible
+		data.Transfers[rid1] = append(data.Transfers[rid1], Transfer{
+			ToStop:      rid2,
+			TimeSeconds: int(dist),
+			Meters:      int(dist),
+			StepFree:    data.Stops[rid1].Wheelchair && data.Stops[rid2].Wheelchair,
+		})
+		transferCount++
+		return nil
+	})
+	return transferCount, err
+}
 
-	log.Printf("RAPTOR Data Load complete in %s", time.Since(start))
-	return data, nil
+// loadTransferRules applies the curated transfers table, returning how many
+// rules took effect.
+func (l *Loader) loadTransferRules(ctx context.Context, tx pgx.Tx, data *RaptorData) (int, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT from_stop_id, to_stop_id, transfer_type,
+		       COALESCE(min_transfer_time_seconds, 120), COALESCE(walk_distance_meters, 0)
+		FROM transfers
+	`)
+	if err != nil {
+		return 0, err
+	}
+	ruleCount := 0
+	var fromDBID, toDBID int
+	var rule TransferRule
+	_, err = pgx.ForEachRow(rows, []any{&fromDBID, &toDBID, &rule.Type, &rule.MinTime, &rule.WalkDistance}, func() error {
+		from, ok1 := data.DBIDToStopID[fromDBID]
+		to, ok2 := data.DBIDToStopID[toDBID]
+		if !ok1 || !ok2 {
+			return nil
+		}
+		rule.From, rule.To = from, to
+		if data.applyTransferRule(rule) {
+			ruleCount++
+		}
+		return nil
+	})
+	return ruleCount, err
 }
@@ -0,0 +1,64 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+)
+
+// The trips of a line direction share a route per stop sequence across the
+// service days they are loaded for.
+func TestPatternVariants(t *testing.T) {
+	data := testNetwork()
+	p := &pattern{
+		lineID:  1,
+		dbStops: []int{1, 2, 3, 4}, // A B C D
+		hops:    []int{0, 600, 600, 600},
+		route:   data.Routes[0],
+		byStops: map[string]*Route{},
+	}
+	for service, rows := range map[string][]scheduleRow{
+		"weekday": {{stopDBID: 1, secs: 28800}},
+		"saturday": {
+			{stopDBID: 1, secs: 28800},
+			{stopDBID: 2, secs: 30000, tripKey: "a"},
+			{stopDBID: 4, secs: 31200, tripKey: "a"},
+		},
+	} {
+		for _, v := range splitVariants(p.dbStops, p.hops, rows) {
+			p.variant(v, data).Services[service] = scheduledTrips(v.stops, v.hops, v.rows, service)
+		}
+	}
+
+	if len(p.variants) != 2 {
+		t.Fatalf("got %d routes, want the whole line and the short turn", len(p.variants))
+	}
+	for _, tc := range []struct {
+		stops    []StopID
+		services int
+	}{
+		{[]StopID{stopA, stopB, stopC, stopD}, 2},
+		{[]StopID{stopB, stopC, stopD}, 1},
+	} {
+		r := p.byStops[stopsKey(dbIDs(data, tc.stops))]
+		if r == nil || !slices.Equal(r.Stops, tc.stops) || len(r.Services) != tc.services || r.LineCode != "L1" {
+			t.Errorf("route over %v: got %+v, want %d services of L1", tc.stops, r, tc.services)
+		}
+	}
+	if len(p.route.Services) != 1 {
+		t.Errorf("the pattern's own route got services: %v", p.route.Services)
+	}
+
+	var report LoadReport
+	report.skip(p, SkipNoSchedules)
+	if !p.skipped || len(report.Skipped) != 1 || report.Skipped[0] != (SkippedLine{LineID: 1, Code: "L1", Reason: SkipNoSchedules}) {
+		t.Errorf("skipping: %v, %+v", p.skipped, report.Skipped)
+	}
+}
+
+func dbIDs(data *RaptorData, stops []StopID) []int {
+	var ids []int
+	for _, s := range stops {
+		ids = append(ids, data.Stops[s].DBID)
+	}
+	return ids
+}
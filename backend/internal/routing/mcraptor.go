@@ -0,0 +1,398 @@
+package routing
+
+import "sort"
+
+// McRAPTOR: the same rounds as FindRoute, but every stop keeps a bag of
+// labels that are Pareto-optimal on (arrival, fare), (arrival, generalized
+// cost) or all three instead of a single earliest arrival. The round number
+// still bounds the transfers, so the result is Pareto-optimal on arrival,
+// transfers and the other criteria.
+
+// mcLabel is one arrival at a stop with the fare paid so far. Labels live in
+// an arena and point back to the label they were reached from.
+type mcLabel struct {
+	arrival int
+	fare    int // centimes
+	ticket  ticket
+	cost    int   // generalized, see CostModel
+	parent  int32 // arena index, -1 at a source
+	stop    StopID
+
+	// How the stop was reached
+	walk      bool
+	routeID   RouteID
+	headway   int   // of a frequency trip, 0 if timetabled
+	boardPos  int32 // index in the route's stops, see label
+	alightPos int32
+	boardTime int
+}
+
+// mcRide is a trip being ridden during a route scan, with the fare state after boarding it.
+type mcRide struct {
+	trip      *Trip
+	offset    int   // added to the trip's stop times, see earliestTrip
+	from      int32 // label boarded from
+	boardPos  int32
+	boardTime int
+	fare      int
+	ticket    ticket
+	cost      int // on boarding
+}
+
+// arrival is the time the ride reaches stop index i.
+func (ride *mcRide) arrival(i int) int {
+	return ride.trip.StopTimes[i].Arrival + ride.offset
+}
+
+// costAt is the generalized cost of the ride on reaching stop index i.
+func (ride *mcRide) costAt(i int) int {
+	return ride.cost + ride.arrival(i) - ride.boardTime
+}
+
+type mcState struct {
+	arena   []mcLabel
+	bags    [][][]int32 // [k][stopID] -> labels not dominated at that stop
+	onBoard [][][]int32 // [k][stopID] -> same, for in-vehicle arrivals only
+	limit   int         // max fare in centimes, 0 for none
+
+	fares bool       // fare and ticket are criteria
+	cost  *CostModel // the generalized cost is a criterion, if set
+
+	// Pooled like queryState: the bags of a stop are those of the current
+	// query once touched, and keep their capacity across queries.
+	marked bitset
+	stops  stopEpochs
+
+	// Reused by findRouteMC
+	queue         *routeQueue
+	transit       []StopID
+	transitLabels []int32
+	rides         []mcRide
+
+	bagBuf, boardBuf [][]int32
+}
+
+// acquireMCState returns a reset state for a search of the given number of
+// rounds. It goes back to the pool with releaseMCState.
+func (r *Raptor) acquireMCState(rounds int) *mcState {
+	n := len(r.Data.Stops)
+	st, _ := r.mcStates.Get().(*mcState)
+	if st == nil {
+		st = &mcState{marked: newBitset(n), queue: newRouteQueue(len(r.Data.Routes))}
+	}
+	st.bagBuf, st.bags = roundViews(st.bagBuf, st.bags, rounds+1, n)
+	st.boardBuf, st.onBoard = roundViews(st.boardBuf, st.onBoard, rounds+1, n)
+	st.stops.begin(n)
+	st.marked.clear()
+	st.arena = st.arena[:0]
+	return st
+}
+
+func (r *Raptor) releaseMCState(st *mcState) {
+	st.cost = nil
+	r.mcStates.Put(st)
+}
+
+// touch makes s part of the current query, with empty bags in every round
+// if it was not yet.
+func (st *mcState) touch(s StopID) {
+	if !st.stops.first(s) {
+		return
+	}
+	for k := range st.bags {
+		st.bags[k][s] = st.bags[k][s][:0]
+		st.onBoard[k][s] = st.onBoard[k][s][:0]
+	}
+}
+
+// bag returns the labels at s in round k.
+func (st *mcState) bag(k int, s StopID) []int32 {
+	if !st.stops.has(s) {
+		return nil
+	}
+	return st.bags[k][s]
+}
+
+// mcDominates reports whether a is at least as good as b on arrival and on
+// the criteria of the search.
+func (r *Raptor) mcDominates(st *mcState, a, b *mcLabel) bool {
+	return a.arrival <= b.arrival &&
+		(!st.fares || a.fare <= b.fare && r.ticketCovers(a.ticket, b.ticket)) &&
+		(st.cost == nil || a.cost <= b.cost)
+}
+
+// tryInsert adds cand to the bag of stop in round k unless a label there
+// dominates it, and drops the labels it dominates. It returns the new label
+// index, or -1 if cand was rejected.
+func (r *Raptor) tryInsert(st *mcState, k int, cand mcLabel) int32 {
+	st.touch(cand.stop)
+	if !r.acceptable(st, st.bags[k][cand.stop], &cand) {
+		return -1
+	}
+	idx := int32(len(st.arena))
+	st.arena = append(st.arena, cand)
+	st.bags[k][cand.stop] = r.merge(st, st.bags[k][cand.stop], idx)
+	return idx
+}
+
+// tryInsertOnBoard is tryInsert for an in-vehicle arrival. The label is kept
+// for footpaths as long as no other in-vehicle arrival dominates it, even if
+// a walk already reaches the stop sooner.
+func (r *Raptor) tryInsertOnBoard(st *mcState, k int, cand mcLabel) int32 {
+	st.touch(cand.stop)
+	if !r.acceptable(st, st.onBoard[k][cand.stop], &cand) {
+		return -1
+	}
+	idx := int32(len(st.arena))
+	st.arena = append(st.arena, cand)
+	st.onBoard[k][cand.stop] = r.merge(st, st.onBoard[k][cand.stop], idx)
+	if r.acceptable(st, st.bags[k][cand.stop], &cand) {
+		st.bags[k][cand.stop] = r.merge(st, st.bags[k][cand.stop], idx)
+	}
+	return idx
+}
+
+// acceptable reports whether no label of bag dominates cand.
+func (r *Raptor) acceptable(st *mcState, bag []int32, cand *mcLabel) bool {
+	for _, idx := range bag {
+		if r.mcDominates(st, &st.arena[idx], cand) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge adds label idx to bag and drops the labels it dominates.
+func (r *Raptor) merge(st *mcState, bag []int32, idx int32) []int32 {
+	kept := bag[:0]
+	for _, old := range bag {
+		if !r.mcDominates(st, &st.arena[idx], &st.arena[old]) {
+			kept = append(kept, old)
+		}
+	}
+	return append(kept, idx)
+}
+
+// findRouteMC is FindRoute with fares, the generalized cost or both as
+// additional criteria.
+func (r *Raptor) findRouteMC(from, to *Place, departureTime int, days []ServiceDay, opts RouteOptions, g *guard) []*Journey {
+	st := r.acquireMCState(opts.rounds())
+	defer r.releaseMCState(st)
+	st.limit = cents(opts.MaxFare)
+	st.fares = opts.fareAware()
+	st.cost = opts.Cost
+
+	marked, queue := st.marked, st.queue
+	for stopID, walkTime := range from.Stops {
+		lbl := mcLabel{arrival: departureTime + walkTime, ticket: noTicket, parent: -1, stop: stopID}
+		if st.cost != nil {
+			lbl.cost = st.cost.walk(walkTime)
+		}
+		r.tryInsert(st, 0, lbl)
+		marked.set(stopID)
+	}
+
+	for k := 1; k < len(st.bags) && g.check(); k++ {
+		// Labels of the previous round stay valid with more trips allowed
+		for _, s := range st.stops.touched {
+			st.bags[k][s] = append(st.bags[k][s][:0], st.bags[k-1][s]...)
+			st.onBoard[k][s] = append(st.onBoard[k][s][:0], st.onBoard[k-1][s]...)
+		}
+
+		// 1. Accumulate routes to process from their earliest marked stop
+		queue.reset()
+		st.transit = marked.appendTo(st.transit[:0])
+		for _, stopID := range st.transit {
+			for _, rs := range r.routesAt(stopID) {
+				queue.addEarliest(rs)
+			}
+		}
+
+		marked.clear()
+		transitLabels := st.transitLabels[:0]
+
+		// 2. Process Routes, carrying a bag of rides instead of a single trip
+		for _, rid := range queue.routes {
+			route := &r.Data.Routes[rid]
+			if !opts.usesRoute(route) {
+				continue
+			}
+			if !g.scan(len(route.Stops) - int(queue.pos[rid])) {
+				break
+			}
+			rides := st.rides[:0]
+
+			for i := int(queue.pos[rid]); i < len(route.Stops); i++ {
+				stopID := route.Stops[i]
+				if !opts.usesStop(&r.Data.Stops[stopID]) {
+					continue
+				}
+
+				for _, ride := range rides {
+					if route.Stops[ride.boardPos] == stopID {
+						continue // back at the boarding stop of a loop
+					}
+					idx := r.tryInsertOnBoard(st, k, mcLabel{
+						arrival:   ride.arrival(i),
+						fare:      ride.fare,
+						ticket:    ride.ticket,
+						cost:      ride.costAt(i),
+						parent:    ride.from,
+						stop:      stopID,
+						routeID:   rid,
+						headway:   ride.trip.Headway,
+						boardPos:  ride.boardPos,
+						alightPos: int32(i),
+						boardTime: ride.boardTime,
+					})
+					if idx >= 0 {
+						transitLabels = append(transitLabels, idx)
+						marked.set(stopID)
+					}
+				}
+
+				for _, from := range st.bag(k-1, stopID) {
+					lbl := st.arena[from]
+					trip, offset := earliestTrip(route, days, i, lbl.arrival)
+					if trip == nil {
+						continue
+					}
+					dep := trip.StopTimes[i].Departure + offset
+					price, held := r.board(lbl.ticket, rid, dep)
+					ride := mcRide{trip: trip, offset: offset, from: from, boardPos: int32(i), boardTime: dep, fare: lbl.fare + price, ticket: held, cost: lbl.cost}
+					if st.limit > 0 && ride.fare > st.limit {
+						continue
+					}
+					if st.cost != nil && lbl.parent >= 0 {
+						// A change of vehicle: nothing is waited for before the first one
+						ride.cost += st.cost.TransferPenalty + st.cost.wait(dep-lbl.arrival)
+					}
+					rides = r.insertRide(st, rides, ride, i)
+				}
+			}
+			st.rides = rides
+		}
+		st.transitLabels = transitLabels
+
+		if g.err != nil {
+			break
+		}
+
+		// 3. Process Transfers from the labels reached on a vehicle this round
+		for _, from := range transitLabels {
+			lbl := st.arena[from]
+			for _, tr := range r.Data.Transfers[lbl.stop] {
+				walkTime, ok := opts.footpath(tr)
+				if !ok {
+					continue
+				}
+				cand := mcLabel{
+					arrival: lbl.arrival + walkTime,
+					fare:    lbl.fare,
+					ticket:  lbl.ticket,
+					cost:    lbl.cost,
+					parent:  from,
+					stop:    tr.ToStop,
+					walk:    true,
+				}
+				if st.cost != nil {
+					cand.cost += st.cost.walk(walkTime)
+				}
+				idx := r.tryInsert(st, k, cand)
+				if idx >= 0 {
+					marked.set(tr.ToStop)
+				}
+			}
+		}
+
+		if marked.empty() {
+			break
+		}
+	}
+
+	// Reconstruction: every label left at a target is a candidate
+	seen := make(map[int32]bool)
+	var journeys []*Journey
+	for k := 1; k < len(st.bags); k++ {
+		for tStop := range to.Stops {
+			for _, idx := range st.bag(k, tStop) {
+				if seen[idx] || st.arena[idx].parent < 0 {
+					continue
+				}
+				seen[idx] = true
+				journeys = append(journeys, r.reconstructMC(st, idx, from, to))
+			}
+		}
+	}
+
+	// Walking all the way, for places that share a stop
+	walkArrival := Infinity
+	for tStop, walkTime := range to.Stops {
+		for _, idx := range st.bag(0, tStop) {
+			if lbl := &st.arena[idx]; lbl.parent < 0 {
+				walkArrival = min(walkArrival, lbl.arrival+walkTime)
+			}
+		}
+	}
+	if walkArrival != Infinity {
+		journeys = append(journeys, r.walkJourney(from, to, departureTime, walkArrival))
+	}
+
+	if st.cost != nil {
+		for _, j := range journeys {
+			j.Cost = st.cost.journeyCost(j)
+		}
+	}
+	result := filterDominated(journeys, func(a, b *Journey) bool {
+		return a.arrival <= b.arrival && a.Transfers <= b.Transfers &&
+			(!st.fares || a.fare <= b.fare) && (st.cost == nil || a.Cost <= b.Cost)
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Transfers != result[j].Transfers {
+			return result[i].Transfers < result[j].Transfers
+		}
+		return result[i].fare < result[j].fare
+	})
+	return result
+}
+
+// insertRide adds ride to the route bag unless a ride already in it reaches
+// stop index i no later and at least as good on the criteria of the search.
+func (r *Raptor) insertRide(st *mcState, rides []mcRide, ride mcRide, i int) []mcRide {
+	better := func(a, b *mcRide) bool {
+		return a.arrival(i) <= b.arrival(i) &&
+			(!st.fares || a.fare <= b.fare && r.ticketCovers(a.ticket, b.ticket)) &&
+			(st.cost == nil || a.costAt(i) <= b.costAt(i))
+	}
+	for j := range rides {
+		if better(&rides[j], &ride) {
+			return rides
+		}
+	}
+	kept := rides[:0]
+	for j := range rides {
+		if !better(&ride, &rides[j]) {
+			kept = append(kept, rides[j])
+		}
+	}
+	return append(kept, ride)
+}
+
+// reconstructMC follows the parent pointers from a target label.
+func (r *Raptor) reconstructMC(st *mcState, idx int32, from, to *Place) *Journey {
+	var legs []Leg
+	for idx >= 0 {
+		lbl := &st.arena[idx]
+		if lbl.parent < 0 {
+			break
+		}
+		parent := &st.arena[lbl.parent]
+		if lbl.walk {
+			legs = append([]Leg{r.walkLeg(parent.stop, lbl.stop, parent.arrival, lbl.arrival)}, legs...)
+		} else {
+			legs = append([]Leg{r.transitLeg(lbl.routeID, int(lbl.boardPos), int(lbl.alightPos), lbl.boardTime, lbl.arrival, lbl.headway)}, legs...)
+		}
+		idx = lbl.parent
+	}
+	return r.newJourney(r.withAccess(legs, from, to))
+}
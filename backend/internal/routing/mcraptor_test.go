@@ -0,0 +1,102 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindRouteCheapest(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+
+	js := mustFind(t)(r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), RouteOptions{Optimize: OptimizeCheapest}))
+	want := []struct {
+		rides string
+		fare  float64
+	}{
+		{"08:00:00-08:30:00 x0: L1 A->D", 5},
+		{"08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D", 10},
+	}
+	if len(js) != len(want) {
+		for _, j := range js {
+			t.Log(describe(j), j.Fare)
+		}
+		t.Fatalf("got %d journeys, want %d", len(js), len(want))
+	}
+	for i, w := range want {
+		checkJourney(t, js[i])
+		if got := describe(js[i]); got != w.rides || js[i].Fare != w.fare {
+			t.Errorf("journey %d is %s for %.2f MAD, want %s for %.2f MAD", i, got, js[i].Fare, w.rides, w.fare)
+		}
+	}
+
+	js = mustFind(t)(r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), RouteOptions{MaxFare: 5}))
+	if len(js) != 1 || js[0].Fare != 5 {
+		t.Fatalf("with a 5 MAD limit got %d journeys, want the direct one", len(js))
+	}
+}
+
+func TestFindRouteTransferDiscount(t *testing.T) {
+	d := testNetwork()
+	d.Fares = []FareRule{{ID: 1, OperatorID: 1, LineType: "tram", Price: 8, TransferAllowed: true, TransferWindow: 3600}}
+	for _, rid := range []int{1, 2} { // L2 and L3
+		d.Routes[rid].LineType = "tram"
+		d.Routes[rid].FareID = 0
+		d.Routes[rid].Price = 8
+	}
+	r := NewRaptor(d)
+
+	js := mustFind(t)(r.FindRoute(context.Background(), at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0}), 28000, ServiceDays("weekday"), RouteOptions{Optimize: OptimizeCheapest}))
+	for _, j := range js {
+		if j.Transfers != 1 {
+			continue
+		}
+		// One ticket for both trams
+		if j.Fare != 8 || j.Legs[0].Fare != 8 || j.Legs[2].Fare != 0 {
+			t.Fatalf("%s costs %.2f MAD (%.2f + %.2f), want 8 + 0", describe(j), j.Fare, j.Legs[0].Fare, j.Legs[2].Fare)
+		}
+		return
+	}
+	t.Fatal("no journey with the tram change")
+}
+
+// Every journey FindRoute finds has to be matched or beaten on arrival,
+// transfers and fare by one of the cheapest search, and the fare limit has
+// to hold.
+func TestFindRouteCheapestCoversFindRoute(t *testing.T) {
+	for seed := int64(0); seed < 40; seed++ {
+		r := NewRaptor(randomNetwork(seed, 60, 25))
+		from, to, ok := randomQuery(seed, 60)
+		if !ok {
+			continue
+		}
+		for dep := 7 * 3600; dep <= 9*3600; dep += 600 {
+			plain := mustFind(t)(r.FindRoute(context.Background(), from, to, dep, ServiceDays("weekday"), RouteOptions{}))
+			cheapest := mustFind(t)(r.FindRoute(context.Background(), from, to, dep, ServiceDays("weekday"), RouteOptions{Optimize: OptimizeCheapest}))
+			for _, j := range cheapest {
+				checkJourney(t, j)
+			}
+			for _, j := range plain {
+				covered := false
+				for _, c := range cheapest {
+					if c.arrival <= j.arrival && c.Transfers <= j.Transfers && c.fare <= j.fare {
+						covered = true
+						break
+					}
+				}
+				if !covered {
+					t.Fatalf("seed %d, leaving at %s: %s is not in the cheapest set", seed, SecondsToTime(dep), describe(j))
+				}
+			}
+			if len(cheapest) == 0 {
+				continue
+			}
+			limit := cheapest[0].Fare
+			for _, j := range mustFind(t)(r.FindRoute(context.Background(), from, to, dep, ServiceDays("weekday"), RouteOptions{MaxFare: limit})) {
+				if j.Fare > limit {
+					t.Fatalf("seed %d: %s costs %.2f MAD, over the %.2f limit", seed, describe(j), j.Fare, limit)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package routing
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Stops of testNetwork
+const (
+	stopA StopID = iota
+	stopB
+	stopC
+	stopD
+	stopE
+)
+
+// testNetwork is a small weekday network with a slow direct line and a
+// faster way with a change:
+//
+//	A -L1-> B -L1-> C -L1-> D   600 s per hop, at 08:00 and 08:20
+//	A -L2-> B                   300 s, at 08:00
+//	B <walk 100 s> E
+//	E -L3-> D                   60 s, at 08:10 and 08:11:40
+func testNetwork() *RaptorData {
+	d := &RaptorData{Transfers: map[StopID][]Transfer{}, DBIDToStopID: map[int]StopID{}}
+	for i, name := range []string{"A", "B", "C", "D", "E"} {
+		d.Stops = append(d.Stops, Stop{ID: StopID(i), DBID: i + 1, Name: name, Lat: 33.5 + float64(i)*0.001, Lon: -7.6})
+		d.DBIDToStopID[i+1] = StopID(i)
+	}
+	d.Routes = []Route{
+		testRoute(0, "L1", []StopID{stopA, stopB, stopC, stopD}, []int{28800, 30000}, 600),
+		testRoute(1, "L2", []StopID{stopA, stopB}, []int{28800}, 300),
+		testRoute(2, "L3", []StopID{stopE, stopD}, []int{29400, 29500}, 60),
+	}
+	d.Transfers[stopB] = []Transfer{{ToStop: stopE, TimeSeconds: 100, Meters: 100}}
+	d.Transfers[stopE] = []Transfer{{ToStop: stopB, TimeSeconds: 100, Meters: 100}}
+	return d
+}
+
+// testRoute builds a weekday bus route whose trips leave at starts and take
+// hop seconds between stops.
+func testRoute(id int, code string, stops []StopID, starts []int, hop int) Route {
+	r := Route{ID: RouteID(id), Stops: stops, LineCode: code, LineType: "bus", LineID: id, FareID: -1, Price: 5}
+	var trips []Trip
+	for ti, start := range starts {
+		t := Trip{ID: TripID(ti), ServiceId: "weekday"}
+		for i := range stops {
+			t.StopTimes = append(t.StopTimes, StopTime{Arrival: start + i*hop, Departure: start + i*hop})
+		}
+		trips = append(trips, t)
+	}
+	r.Services = map[string]*TripTable{"weekday": NewTripTable(trips)}
+	return r
+}
+
+// randomNetwork builds a network of random bus, tram and busway routes over
+// stops scattered across Casablanca, running every day from about 05:00 to
+// 23:00, with random footpaths. The same seed gives the same network.
+func randomNetwork(seed int64, numStops, numRoutes int) *RaptorData {
+	rng := rand.New(rand.NewSource(seed))
+	d := &RaptorData{Transfers: map[StopID][]Transfer{}, DBIDToStopID: map[int]StopID{}}
+	d.Fares = []FareRule{
+		{ID: 1, OperatorID: 1, LineType: "tram", Price: 8, TransferAllowed: true, TransferWindow: 3600},
+		{ID: 2, OperatorID: 1, LineType: "busway", Price: 8, TransferAllowed: true, TransferWindow: 3600},
+		{ID: 3, OperatorID: 1, LineType: "bus", Price: 5},
+	}
+	for i := 0; i < numStops; i++ {
+		d.Stops = append(d.Stops, Stop{ID: StopID(i), DBID: i + 1, Name: fmt.Sprint("S", i), Lat: 33.5 + rng.Float64()*0.1, Lon: -7.6 + rng.Float64()*0.1})
+		d.DBIDToStopID[i+1] = StopID(i)
+	}
+	lineTypes := []string{"bus", "tram", "busway"}
+	for ri := 0; ri < numRoutes; ri++ {
+		n := 3 + rng.Intn(8)
+		stops := make([]StopID, n)
+		for i, p := range rng.Perm(numStops)[:n] {
+			stops[i] = StopID(p)
+		}
+		r := Route{ID: RouteID(ri), Stops: stops, LineCode: fmt.Sprint("L", ri), LineType: lineTypes[rng.Intn(3)], LineID: ri}
+		r.FareID = d.findFare(1, r.LineType)
+		r.Price = d.Fares[r.FareID].Price
+		hops := make([]int, n)
+		for i := range hops {
+			hops[i] = 60 + rng.Intn(400)
+		}
+		r.Services = map[string]*TripTable{}
+		for _, day := range []string{"weekday", "saturday", "sunday"} {
+			var trips []Trip
+			headway := 300 + rng.Intn(1500)
+			for start := 5*3600 + rng.Intn(1800); start < 23*3600; start += headway {
+				t := Trip{ID: TripID(len(trips)), ServiceId: day}
+				c := start
+				for i := range stops {
+					t.StopTimes = append(t.StopTimes, StopTime{Arrival: c, Departure: c + 20})
+					c += 20 + hops[i]
+				}
+				trips = append(trips, t)
+			}
+			r.Services[day] = NewTripTable(trips)
+		}
+		d.Routes = append(d.Routes, r)
+	}
+	for i := 0; i < numStops; i++ {
+		for j := 0; j < numStops; j++ {
+			if i != j && rng.Intn(numStops) < 2 {
+				walk := 60 + rng.Intn(300)
+				d.Transfers[StopID(i)] = append(d.Transfers[StopID(i)], Transfer{ToStop: StopID(j), TimeSeconds: walk, Meters: walk})
+				d.Transfers[StopID(j)] = append(d.Transfers[StopID(j)], Transfer{ToStop: StopID(i), TimeSeconds: walk, Meters: walk})
+			}
+		}
+	}
+	return d
+}
+
+// at is a place reaching each of its stops in the given number of seconds.
+func at(stops map[StopID]int) Place {
+	return Place{Name: "place", Stops: stops}
+}
+
+// randomQuery picks an origin and a destination of two stops each on a
+// randomNetwork of numStops stops, or ok false if they overlap.
+func randomQuery(seed int64, numStops int) (from, to Place, ok bool) {
+	n := int64(numStops)
+	from = at(map[StopID]int{StopID(seed % n): 0, StopID((seed + 11) % n): 240})
+	to = at(map[StopID]int{StopID((seed*7 + 3) % n): 0, StopID((seed*7 + 20) % n): 180})
+	for s := range from.Stops {
+		if _, overlap := to.Stops[s]; overlap {
+			return from, to, false
+		}
+	}
+	return from, to, true
+}
+
+// checkJourney fails t unless the legs of j follow each other in time and
+// space.
+func checkJourney(t *testing.T, j *Journey) {
+	t.Helper()
+	prev := -Infinity
+	for i, leg := range j.Legs {
+		if leg.start < prev {
+			t.Fatalf("leg %d starts at %s, before the previous one ends", i, leg.StartTime)
+		}
+		if leg.end < leg.start {
+			t.Fatalf("leg %d ends before it starts: %s-%s", i, leg.StartTime, leg.EndTime)
+		}
+		if i > 0 && leg.FromStop.ID != j.Legs[i-1].ToStop.ID {
+			t.Fatalf("leg %d starts at %s, the previous one ends at %s", i, leg.FromStop.Name, j.Legs[i-1].ToStop.Name)
+		}
+		prev = leg.end
+	}
+}
+
+// describe sums up a journey as its times and rides, the times followed by
+// the day they fall on when not the queried one.
+func describe(j *Journey) string {
+	s := j.DepartureTime
+	if j.DepartureDay != 0 {
+		s += fmt.Sprintf("%+d", j.DepartureDay)
+	}
+	s += "-" + j.ArrivalTime
+	if j.ArrivalDay != 0 {
+		s += fmt.Sprintf("%+d", j.ArrivalDay)
+	}
+	s += fmt.Sprintf(" x%d:", j.Transfers)
+	for _, leg := range j.Legs {
+		if leg.Type == "walk" {
+			s += fmt.Sprintf(" walk %s->%s", leg.FromStop.Name, leg.ToStop.Name)
+		} else {
+			s += fmt.Sprintf(" %s %s->%s", leg.RouteCode, leg.FromStop.Name, leg.ToStop.Name)
+		}
+	}
+	return s
+}
+
+// mustFind returns the journeys of a search that is not expected to stop early.
+func mustFind(t *testing.T) func([]*Journey, error) []*Journey {
+	return func(js []*Journey, err error) []*Journey {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("search stopped: %v", err)
+		}
+		return js
+	}
+}
@@ -0,0 +1,161 @@
+package routing
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	OptimizeFastest  = "fastest"
+	OptimizeCheapest = "cheapest"
+)
+
+// LineTypes are the values of lines.line_type, usable in RouteOptions.Modes.
+var LineTypes = []string{"tram", "busway", "bus", "train", "grand_taxi"}
+
+// RouteOptions tunes a journey search. The zero value is a plain earliest-arrival query.
+type RouteOptions struct {
+	MaxFare    float64 // MAD, 0 means no limit
+	Optimize   string  // OptimizeFastest (default) or OptimizeCheapest
+	Wheelchair bool    // board and alight only at accessible stops, on step-free vehicles and footpaths
+
+	Modes        []string // line types to ride, e.g. "tram", "busway"; empty means all
+	ExcludeLines []string // line codes never to ride, e.g. "L7", matched as by sameLineCode
+
+	MaxRides  int     // vehicles per journey, one more than the transfers; 0 means MaxRounds
+	WalkSpeed float64 // m/s, 0 means WalkSpeed
+	MaxWalk   float64 // meters walked to, from or between stops, 0 means DefaultMaxWalk
+
+	Cost *CostModel // ranks the journeys by generalized cost, see CostModel; nil ranks by arrival and transfers
+
+	Budget Budget // work allowed, zero fields mean those of DefaultBudget
+}
+
+// MaxRoundsLimit caps RouteOptions.MaxRides.
+const MaxRoundsLimit = 10
+
+// rounds returns the number of RAPTOR rounds of the search.
+func (o RouteOptions) rounds() int {
+	if o.MaxRides <= 0 {
+		return MaxRounds
+	}
+	return min(o.MaxRides, MaxRoundsLimit)
+}
+
+func (o RouteOptions) walkSpeed() float64 {
+	if o.WalkSpeed <= 0 {
+		return WalkSpeed
+	}
+	return o.WalkSpeed
+}
+
+func (o RouteOptions) maxWalk() float64 {
+	if o.MaxWalk <= 0 {
+		return DefaultMaxWalk
+	}
+	return o.MaxWalk
+}
+
+// footpath returns the time to walk a footpath, and whether the search may
+// walk it at all. Footpaths are timed at 1 m/s, which also makes up for the
+// crossings, and their time scales with the walking speed relative to
+// WalkSpeed; the curated minimum time of a change does not.
+func (o RouteOptions) footpath(tr Transfer) (int, bool) {
+	if !o.usesTransfer(tr) || float64(tr.Meters) > o.maxWalk() {
+		return 0, false
+	}
+	if o.WalkSpeed <= 0 || tr.MinTime {
+		return tr.TimeSeconds, true
+	}
+	return int(math.Ceil(float64(tr.TimeSeconds) * WalkSpeed / o.WalkSpeed)), true
+}
+
+// usesRoute reports whether the search may ride route.
+func (o RouteOptions) usesRoute(route *Route) bool {
+	if o.Wheelchair && !route.Accessible {
+		return false
+	}
+	if len(o.Modes) > 0 {
+		allowed := false
+		for _, m := range o.Modes {
+			if m == route.LineType {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	for _, code := range o.ExcludeLines {
+		if sameLineCode(code, route.LineCode) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameLineCode reports whether two line codes name the same line. Bus lines
+// are stored as "L5", "L005" or just "5" depending on the source, so the "L"
+// and leading zeros of a numbered line do not count; other codes, like the
+// tram's "T1", compare ignoring case.
+func sameLineCode(a, b string) bool {
+	return strings.EqualFold(lineNumber(a), lineNumber(b))
+}
+
+// lineNumber strips the "L" and leading zeros of a numbered line code.
+func lineNumber(code string) string {
+	code = strings.TrimSpace(code)
+	if len(code) > 1 && (code[0] == 'L' || code[0] == 'l') && isDigit(code[1]) {
+		code = code[1:]
+	}
+	if len(code) > 1 && code[0] == '0' {
+		if n := strings.TrimLeft(code, "0"); n != "" {
+			return n
+		}
+		return "0"
+	}
+	return code
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// fareAware reports whether the search has to carry fares as a criterion.
+func (o RouteOptions) fareAware() bool {
+	return o.MaxFare > 0 || o.Optimize == OptimizeCheapest
+}
+
+// apply sets the generalized cost of the journeys, drops those over the fare
+// limit and orders them: cheapest (then shortest) first when optimizing for
+// price, else by cost when a cost model is given. The order is otherwise
+// unchanged.
+func (o RouteOptions) apply(journeys []*Journey) []*Journey {
+	model := o.costModel()
+	for _, j := range journeys {
+		j.Cost = model.journeyCost(j)
+	}
+	if o.MaxFare > 0 {
+		limit := cents(o.MaxFare)
+		kept := journeys[:0]
+		for _, j := range journeys {
+			if j.fare <= limit {
+				kept = append(kept, j)
+			}
+		}
+		journeys = kept
+	}
+	if o.Optimize == OptimizeCheapest {
+		sort.SliceStable(journeys, func(i, j int) bool {
+			if journeys[i].fare != journeys[j].fare {
+				return journeys[i].fare < journeys[j].fare
+			}
+			return journeys[i].Duration < journeys[j].Duration
+		})
+	} else if o.Cost != nil {
+		sort.SliceStable(journeys, func(i, j int) bool {
+			return journeys[i].Cost < journeys[j].Cost
+		})
+	}
+	return journeys
+}
@@ -0,0 +1,48 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSameLineCode(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		same bool
+	}{
+		{"L5", "L5", true},
+		{"L005", "L5", true},
+		{"5", "L5", true},
+		{"l05", "5", true},
+		{" L7 ", "L7", true},
+		{"T1", "t1", true},
+		{"L5", "L50", false},
+		{"L5", "T5", false},
+		{"BW1", "1", false},
+		{"L", "", false},
+		{"L0", "0", true},
+	} {
+		if got := sameLineCode(tc.a, tc.b); got != tc.same {
+			t.Errorf("sameLineCode(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.same)
+		}
+	}
+}
+
+func TestFindRouteExcludeLines(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+	for _, code := range []string{"L1", "L001", "1"} {
+		js := mustFind(t)(r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), RouteOptions{ExcludeLines: []string{code}}))
+		if len(js) != 1 || describe(js[0]) != "08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D" {
+			for _, j := range js {
+				t.Log(describe(j))
+			}
+			t.Fatalf("excluding %q: want only the change", code)
+		}
+	}
+
+	js := mustFind(t)(r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), RouteOptions{Modes: []string{"tram"}}))
+	if len(js) != 0 {
+		t.Fatalf("got %d journeys by tram on a bus network, want none", len(js))
+	}
+}
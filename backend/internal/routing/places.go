@@ -0,0 +1,110 @@
+package routing
+
+import "math"
+
+const (
+	// WalkSpeed is the walking speed of access and egress legs in m/s, the
+	// same as the database's estimate_walk_time_seconds.
+	WalkSpeed = 1.2
+
+	// DefaultMaxWalk is how far, in meters, a rider is expected to walk to or from a stop.
+	DefaultMaxWalk = 1000.0
+)
+
+// Place is where a journey starts or ends: a point and the stops within
+// walking distance of it, with the time it takes to walk to each.
+type Place struct {
+	Name  string
+	Lat   float64
+	Lon   float64
+	Stops map[StopID]int // stop -> walking seconds
+
+	stepFree bool // the walks to the stops keep off steps
+}
+
+// PlaceAt returns the place at a coordinate, reaching every stop within
+// maxWalk meters along the streets, or as the crow flies without a street
+// network or away from it.
+func (r *Raptor) PlaceAt(name string, lat, lon, maxWalk float64) Place {
+	return r.place(name, lat, lon, maxWalk, WalkSpeed, false)
+}
+
+// PlaceFor is PlaceAt with the walking distance and speed of the options. For
+// a wheelchair, the stops are those reached along streets without steps.
+func (r *Raptor) PlaceFor(name string, lat, lon float64, opts RouteOptions) Place {
+	return r.place(name, lat, lon, opts.maxWalk(), opts.walkSpeed(), opts.Wheelchair)
+}
+
+func (r *Raptor) place(name string, lat, lon, maxWalk, speed float64, stepFree bool) Place {
+	p := Place{Name: name, Lat: lat, Lon: lon, Stops: make(map[StopID]int), stepFree: stepFree}
+
+	if r.Data.Streets != nil {
+		onNetwork := streetDistances(r.Data.Streets, r.stopsAtNode, r.snaps, lat, lon, maxWalk, stepFree, func(s StopID, meters float64, _ bool) {
+			p.Stops[s] = walkSeconds(meters, speed)
+		})
+		if onNetwork {
+			return p
+		}
+	}
+
+	// Cheap bounding box first, then the exact distance
+	dLat := maxWalk / 111320
+	dLon := dLat / math.Cos(lat*math.Pi/180)
+	for i := range r.Data.Stops {
+		s := &r.Data.Stops[i]
+		if math.Abs(s.Lat-lat) > dLat || math.Abs(s.Lon-lon) > dLon {
+			continue
+		}
+		if d := DistanceMeters(lat, lon, s.Lat, s.Lon); d <= maxWalk {
+			p.Stops[s.ID] = walkSeconds(d, speed)
+		}
+	}
+	return p
+}
+
+// stop returns the stand-in for the place in the legs of a journey.
+func (p *Place) stop() Stop {
+	return Stop{ID: -1, Name: p.Name, Lat: p.Lat, Lon: p.Lon}
+}
+
+// withAccess adds the walk from the origin to the first stop, timed to arrive
+// as the first leg starts, and the walk from the last stop to the destination.
+func (r *Raptor) withAccess(legs []Leg, from, to *Place) []Leg {
+	if len(legs) == 0 {
+		return legs
+	}
+	first, last := &legs[0], &legs[len(legs)-1]
+	if w := from.Stops[first.FromStop.ID]; w > 0 {
+		access := r.placeWalkLeg(from.stop(), first.FromStop, first.start-w, first.start, from.stepFree)
+		legs = append([]Leg{access}, legs...)
+	}
+	if w := to.Stops[last.ToStop.ID]; w > 0 {
+		legs = append(legs, r.placeWalkLeg(last.ToStop, to.stop(), last.end, last.end+w, to.stepFree))
+	}
+	return legs
+}
+
+// walkJourney is the journey walking all the way, leaving from at departure
+// and reaching to at arrival, for places that share a stop.
+func (r *Raptor) walkJourney(from, to *Place, departure, arrival int) *Journey {
+	return r.newJourney([]Leg{r.placeWalkLeg(from.stop(), to.stop(), departure, arrival, from.stepFree)})
+}
+
+// placeWalkLeg builds a walk between a place and a stop, keeping off steps if
+// stepFree. It is accessible unless the streets it follows take steps.
+func (r *Raptor) placeWalkLeg(from, to Stop, start, end int, stepFree bool) Leg {
+	geom, steps := r.walkGeometry(from.Lat, from.Lon, to.Lat, to.Lon, stepFree)
+	return Leg{
+		Type:       "walk",
+		Accessible: !steps,
+		FromStop:   from,
+		ToStop:     to,
+		StartTime:  SecondsToTime(start),
+		EndTime:    SecondsToTime(end),
+		Duration:   end - start,
+		Stops:      []Stop{from, to},
+		Geometry:   geom,
+		start:      start,
+		end:        end,
+	}
+}
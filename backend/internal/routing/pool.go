@@ -0,0 +1,102 @@
+package routing
+
+import "math/bits"
+
+// Query scratch memory. Every search needs per-round arrays over all stops;
+// allocating them per query is most of what a query allocates, so each
+// Raptor keeps them in pools (see acquireQueryState and the like) and a state
+// is only reset, never cleared, between queries.
+
+// stopEpochs tells the stops a pooled state has touched in the current
+// query from the ones still holding values of an earlier query. Starting a
+// query is a counter increment instead of a pass over every stop, and the
+// per-round passes only visit the touched stops.
+type stopEpochs struct {
+	stamp   []uint32 // per stop, the epoch of the query that last touched it
+	epoch   uint32
+	touched []StopID // in the current query, in the order they were touched
+}
+
+// begin starts a query over numStops stops.
+func (e *stopEpochs) begin(numStops int) {
+	if len(e.stamp) != numStops {
+		e.stamp = make([]uint32, numStops)
+		e.epoch = 0
+	}
+	e.epoch++
+	if e.epoch == 0 {
+		// Wrapped around: stamps of 4 billion queries ago would look current
+		clear(e.stamp)
+		e.epoch = 1
+	}
+	e.touched = e.touched[:0]
+}
+
+// has reports whether s was touched in the current query.
+func (e *stopEpochs) has(s StopID) bool {
+	return e.stamp[s] == e.epoch
+}
+
+// first marks s touched and reports whether it was not yet, in which case
+// the caller has to reset the values of s.
+func (e *stopEpochs) first(s StopID) bool {
+	if e.stamp[s] == e.epoch {
+		return false
+	}
+	e.stamp[s] = e.epoch
+	e.touched = append(e.touched, s)
+	return true
+}
+
+// bitset is a set of stops, one bit per stop.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(s StopID) {
+	b[s/64] |= 1 << (s % 64)
+}
+
+func (b bitset) has(s StopID) bool {
+	return b[s/64]&(1<<(s%64)) != 0
+}
+
+func (b bitset) clear() {
+	clear(b)
+}
+
+// empty reports whether no stop is set.
+func (b bitset) empty() bool {
+	for _, w := range b {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTo appends the stops of the set to ids in increasing order.
+func (b bitset) appendTo(ids []StopID) []StopID {
+	for i, w := range b {
+		for w != 0 {
+			ids = append(ids, StopID(i*64+bits.TrailingZeros64(w)))
+			w &= w - 1
+		}
+	}
+	return ids
+}
+
+// roundViews slices buf, grown if needed, into rounds views of n values each.
+func roundViews[T any](buf []T, views [][]T, rounds, n int) ([]T, [][]T) {
+	if cap(buf) < rounds*n {
+		buf = make([]T, rounds*n)
+	}
+	buf = buf[:rounds*n]
+	views = views[:0]
+	for k := 0; k < rounds; k++ {
+		views = append(views, buf[k*n:(k+1)*n:(k+1)*n])
+	}
+	return buf, views
+}
@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// poolQuery is a query of any kind, for checking that pooled state left by
+// one kind does not leak into another.
+type poolQuery struct {
+	kind     int
+	from, to Place
+	at       int
+	opts     RouteOptions
+}
+
+func (q poolQuery) run(r *Raptor) ([]*Journey, error) {
+	days := ServiceDays("weekday")
+	switch q.kind {
+	case 0:
+		return r.FindRoute(context.Background(), q.from, q.to, q.at, days, q.opts)
+	case 1:
+		return r.FindRouteArriveBy(context.Background(), q.from, q.to, q.at+2*3600, days, q.opts)
+	case 2:
+		return r.FindRange(context.Background(), q.from, q.to, q.at, q.at+3600, days, q.opts)
+	default:
+		return r.FindRoute(context.Background(), q.from, q.to, q.at, days, q.opts)
+	}
+}
+
+// poolQueries mixes every kind of search, with round counts going up and
+// down so that pooled states are reused at other sizes.
+func poolQueries(numStops, n int) []poolQuery {
+	rng := rand.New(rand.NewSource(3))
+	queries := make([]poolQuery, n)
+	for i := range queries {
+		q := poolQuery{
+			kind: i % 5,
+			from: at(map[StopID]int{StopID(rng.Intn(numStops)): 0, StopID(rng.Intn(numStops)): 120}),
+			to:   at(map[StopID]int{StopID(rng.Intn(numStops)): 0, StopID(rng.Intn(numStops)): 90}),
+			at:   7*3600 + rng.Intn(36000),
+			opts: RouteOptions{MaxRides: 1 + rng.Intn(8)},
+		}
+		switch q.kind {
+		case 3:
+			q.opts.Optimize = OptimizeCheapest
+		case 4:
+			q.opts.Cost = &DefaultCostModel
+		}
+		queries[i] = q
+	}
+	return queries
+}
+
+func summary(js []*Journey) string {
+	s := ""
+	for _, j := range js {
+		s += fmt.Sprintf("%s fare %d cost %d; ", describe(j), j.fare, j.Cost)
+	}
+	return s
+}
+
+func TestPooledStateMatchesFresh(t *testing.T) {
+	d := randomNetwork(7, 200, 60)
+	queries := poolQueries(len(d.Stops), 100)
+
+	// A Raptor per query starts from empty pools
+	want := make([]string, len(queries))
+	for i, q := range queries {
+		js := mustFind(t)(q.run(NewRaptor(d)))
+		for _, j := range js {
+			checkJourney(t, j)
+		}
+		want[i] = summary(js)
+	}
+
+	// One Raptor, in turn then at once, reuses its pooled states
+	r := NewRaptor(d)
+	for i, q := range queries {
+		if got := summary(mustFind(t)(q.run(r))); got != want[i] {
+			t.Fatalf("query %d (kind %d): got %s, want %s", i, q.kind, got, want[i])
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan string, len(queries))
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(queries); i += 4 {
+				js, err := queries[i].run(r)
+				if got := summary(js); err != nil || got != want[i] {
+					errs <- fmt.Sprintf("concurrent query %d: got %s (%v), want %s", i, got, err, want[i])
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+}
+
+func TestStopEpochsWrap(t *testing.T) {
+	var e stopEpochs
+	e.begin(4)
+	e.first(2)
+	e.epoch = ^uint32(0) - 1
+	e.stamp[1] = e.epoch + 1 // as if touched by the query about to start
+
+	e.begin(4)
+	if !e.has(1) {
+		t.Fatal("stop 1 not touched in the last epoch before wrapping")
+	}
+	e.begin(4) // wraps to 0 and clears the stamps
+	if e.has(1) || e.has(2) || e.epoch != 1 {
+		t.Fatalf("after wrapping: epoch %d, stamps %v", e.epoch, e.stamp)
+	}
+	if !e.first(3) || e.first(3) || len(e.touched) != 1 {
+		t.Fatalf("touched %v", e.touched)
+	}
+}
+
+func TestBitset(t *testing.T) {
+	b := newBitset(130)
+	if !b.empty() {
+		t.Fatal("new bitset not empty")
+	}
+	for _, s := range []StopID{129, 0, 64, 63} {
+		b.set(s)
+	}
+	if got := fmt.Sprint(b.appendTo(nil)); got != "[0 63 64 129]" || !b.has(64) || b.has(65) {
+		t.Fatalf("set %s", got)
+	}
+	b.clear()
+	if !b.empty() {
+		t.Fatal("cleared bitset not empty")
+	}
+}
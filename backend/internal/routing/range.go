@@ -0,0 +1,98 @@
+package routing
+
+import (
+	"context"
+	"sort"
+)
+
+// FindRange answers a profile query: every non-dominated journey that leaves
+// from between windowStart and windowEnd (seconds since midnight).
+//
+// This is rRAPTOR: the departure times at which a source trip can be caught
+// are processed from latest to earliest, and each run reuses the arrival
+// labels of the previous one. A run only improves stops that the earlier
+// departure reaches sooner, so the extra runs are cheap.
+// The result is ordered by departure time, then by number of transfers.
+// Fares are only used to filter and order the result. A search stopped by ctx
+// or the budget returns the journeys of the departures it went through, as
+// FindRoute does.
+func (r *Raptor) FindRange(ctx context.Context, from, to Place, windowStart, windowEnd int, days []ServiceDay, opts RouteOptions) ([]*Journey, error) {
+	departures := r.sourceDepartures(from.Stops, windowStart, windowEnd, days, opts)
+	if len(departures) == 0 {
+		return nil, nil
+	}
+	g := opts.guard(ctx)
+
+	st := r.acquireQueryState(opts.rounds())
+	defer r.releaseQueryState(st)
+	// A run could otherwise wait at a source stop for a trip that leaves
+	// after the window, and that journey would hide the ones inside it
+	st.leaveBy = windowEnd
+	floor := make([]int, len(st.rounds))
+	var journeys []*Journey
+
+	for _, dep := range departures {
+		for k := range floor {
+			floor[k], _ = st.bestTarget(k, &to)
+		}
+
+		st.seed(from.Stops, dep)
+		r.runRounds(st, days, opts, g)
+
+		journeys = append(journeys, r.collectJourneys(st, &from, &to, floor)...)
+		if g.err != nil {
+			break
+		}
+	}
+
+	return opts.apply(profileFilter(journeys)), g.err
+}
+
+// sourceDepartures lists the distinct times, latest first, at which leaving
+// the origin lets the rider catch a trip at one of the source stops.
+func (r *Raptor) sourceDepartures(sourceStops map[StopID]int, windowStart, windowEnd int, days []ServiceDay, opts RouteOptions) []int {
+	seen := make(map[int]bool)
+	var departures []int
+
+	for stopID, walkTime := range sourceStops {
+		if !opts.usesStop(&r.Data.Stops[stopID]) {
+			continue
+		}
+		for _, rs := range r.routesAt(stopID) {
+			route := &r.Data.Routes[rs.route]
+			if !opts.usesRoute(route) {
+				continue
+			}
+			for _, day := range days {
+				from := windowStart + walkTime - day.Offset
+				to := windowEnd + walkTime - day.Offset
+				for _, t := range route.TripsOn(day.ServiceID).DeparturesBetween(int(rs.pos), from, to) {
+					dep := t + day.Offset - walkTime
+					if seen[dep] {
+						continue
+					}
+					seen[dep] = true
+					departures = append(departures, dep)
+				}
+			}
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(departures)))
+	return departures
+}
+
+// profileFilter keeps the journeys not dominated on (later departure,
+// earlier arrival, fewer transfers) and orders them by departure time.
+func profileFilter(journeys []*Journey) []*Journey {
+	result := filterDominated(journeys, func(a, b *Journey) bool {
+		return a.departure >= b.departure && a.arrival <= b.arrival && a.Transfers <= b.Transfers
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].departure != result[j].departure {
+			return result[i].departure < result[j].departure
+		}
+		return result[i].Transfers < result[j].Transfers
+	})
+	return result
+}
@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindRangeProfile(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	js := mustFind(t)(r.FindRange(context.Background(), at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0}), 28000, 31000, ServiceDays("weekday"), RouteOptions{}))
+
+	// Ordered by departure, then transfers: at 08:00 the change arrives
+	// sooner and the direct trip has none
+	want := []string{
+		"08:00:00-08:30:00 x0: L1 A->D",
+		"08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D",
+		"08:20:00-08:50:00 x0: L1 A->D",
+	}
+	if len(js) != len(want) {
+		for _, j := range js {
+			t.Log(describe(j))
+		}
+		t.Fatalf("got %d journeys, want %d", len(js), len(want))
+	}
+	for i, w := range want {
+		checkJourney(t, js[i])
+		if got := describe(js[i]); got != w {
+			t.Errorf("journey %d is %s, want %s", i, got, w)
+		}
+	}
+}
+
+// Every journey FindRoute finds leaving within the window has to be matched
+// or beaten by one of the profile.
+func TestFindRangeCoversFindRoute(t *testing.T) {
+	const windowStart, windowEnd = 7 * 3600, 8 * 3600
+	for seed := int64(0); seed < 40; seed++ {
+		r := NewRaptor(randomNetwork(seed, 60, 25))
+		from, to, ok := randomQuery(seed, 60)
+		if !ok {
+			continue
+		}
+		profile := mustFind(t)(r.FindRange(context.Background(), from, to, windowStart, windowEnd, ServiceDays("weekday"), RouteOptions{}))
+		for _, j := range profile {
+			checkJourney(t, j)
+		}
+		for dep := windowStart; dep <= windowEnd; dep += 60 {
+			for _, j := range mustFind(t)(r.FindRoute(context.Background(), from, to, dep, ServiceDays("weekday"), RouteOptions{})) {
+				if j.departure > windowEnd {
+					continue
+				}
+				covered := false
+				for _, p := range profile {
+					if p.departure >= j.departure && p.arrival <= j.arrival && p.Transfers <= j.Transfers {
+						covered = true
+						break
+					}
+				}
+				if !covered {
+					t.Fatalf("seed %d, leaving at %s: %s is not in the profile", seed, SecondsToTime(dep), describe(j))
+				}
+			}
+		}
+	}
+}
+
+func TestFindRangeStaysInWindow(t *testing.T) {
+	const windowStart, windowEnd = 8 * 3600, 8*3600 + 1800
+	d := randomNetwork(2, 300, 80)
+	r := NewRaptor(d)
+	total := 0
+	for seed := int64(0); seed < 60; seed++ {
+		from, to, ok := randomQuery(seed, len(d.Stops))
+		if !ok {
+			continue
+		}
+		js := mustFind(t)(r.FindRange(context.Background(), from, to, windowStart, windowEnd, ServiceDays("weekday"), RouteOptions{}))
+		for _, j := range js {
+			checkJourney(t, j)
+			if dep, err := ParseServiceTime(j.DepartureTime); err != nil || dep < windowStart || dep > windowEnd {
+				t.Fatalf("seed %d: %s leaves outside 08:00-08:30", seed, describe(j))
+			}
+		}
+		total += len(js)
+	}
+	if total == 0 {
+		t.Fatal("no journeys in the window")
+	}
+}
@@ -1,217 +1,407 @@
 package routing
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"sort"
+	"sync"
+	"time"
 )
 
 const (
-	MaxRounds     = 6
-	Infinity      = math.MaxInt32
-	TransferCost  = 60 // penalty in seconds
-	WalkRouteID   = -1 // Added for walk legs in reconstruction
+	MaxRounds    = 6 // default, see RouteOptions.MaxRides
+	Infinity     = math.MaxInt32
+	TransferCost = 60 // penalty in seconds, see DefaultCostModel
 )
 
 type Raptor struct {
 	Data *RaptorData
+
+	// Lookup indexes, built once by NewRaptor (see buildIndexes)
+	stopRoutes      []routeStop
+	stopRoutesStart []int32
+	inbound         []Transfer
+	inboundStart    []int32
+
+	// Stops on the street network, when Data.Streets is set
+	snaps       []streetSnap
+	stopsAtNode map[int32][]StopID
+
+	// Scratch memory of finished queries, see pool.go
+	queryStates   sync.Pool // *queryState
+	reverseStates sync.Pool // *reverseState
+	mcStates      sync.Pool // *mcState
 }
 
 func NewRaptor(data *RaptorData) *Raptor {
-	return &Raptor{Data: data}
+	r := &Raptor{Data: data}
+	r.buildIndexes()
+	return r
 }
 
 type Journey struct {
-	Legs []Leg `json:"legs"`
+	DepartureTime string    `json:"departureTime"`
+	ArrivalTime   string    `json:"arrivalTime"`
+	Duration      int       `json:"duration"`     // seconds from first boarding/walk to arrival
+	Transfers     int       `json:"transfers"`    // number of vehicle changes
+	Fare          float64   `json:"fare"`         // total MAD, transfer discounts applied
+	Cost          int       `json:"cost"`         // generalized cost in seconds, see CostModel
+	DepartureDay  int       `json:"departureDay"` // days after the queried service day, -1 for the day before
+	ArrivalDay    int       `json:"arrivalDay"`
+	Accessible    bool      `json:"accessible"`           // every leg is wheelchair accessible
+	DepartureAt   time.Time `json:"departureAt,omitzero"` // ISO-8601 in Casablanca time, see SetDate
+	ArrivalAt     time.Time `json:"arrivalAt,omitzero"`
+	Legs          []Leg     `json:"legs"`
+
+	departure int
+	arrival   int
+	fare      int // centimes
 }
 
 type Leg struct {
-	Type       string `json:"type"` // "transit" or "walk"
-	FromStop   Stop   `json:"fromStop"`
-	ToStop     Stop   `json:"toStop"`
-	StartTime  string `json:"startTime"`
-	EndTime    string `json:"endTime"`
-	Duration   int    `json:"duration"`
-	RouteCode  string `json:"routeCode"`
-	RouteColor string `json:"routeColor"`
-	WaitTime   int    `json:"waitTime"`
-	Stops      []Stop        `json:"stops,omitempty"`
-	Geometry   [][2]float64  `json:"geometry,omitempty"`
+	Type       string       `json:"type"` // "transit" or "walk"
+	FromStop   Stop         `json:"fromStop"`
+	ToStop     Stop         `json:"toStop"`
+	StartTime  string       `json:"startTime"`
+	EndTime    string       `json:"endTime"`
+	Duration   int          `json:"duration"`
+	RouteCode  string       `json:"routeCode"`
+	RouteColor string       `json:"routeColor"`
+	WaitTime   int          `json:"waitTime"`            // expected wait on a frequency line
+	Headway    int          `json:"headway,omitempty"`   // seconds between departures of a frequency line
+	Frequency  string       `json:"frequency,omitempty"` // "every ~8 min" instead of exact times
+	Fare       float64      `json:"fare,omitempty"`      // MAD paid when boarding, 0 if covered by a transfer
+	Accessible bool         `json:"accessible"`          // step-free stops and vehicle, or step-free footpath
+	Stops      []Stop       `json:"stops,omitempty"`
+	Geometry   [][2]float64 `json:"geometry,omitempty"`
+
+	start   int // seconds since midnight
+	end     int
+	routeID RouteID
 }
 
-// FindRoute finds the best route from source stops to target stops
-// sourceStops: map[StopID]int (stop -> initial walk time)
-func (r *Raptor) FindRoute(sourceStops map[StopID]int, targetStops map[StopID]bool, departureTime int, dayType string) *Journey {
-	// Initialize
-	rounds := make([][]int, MaxRounds+1) // [k][stopID] -> earliest arrival time
-	for k := 0; k <= MaxRounds; k++ {
-		rounds[k] = make([]int, len(r.Data.Stops))
-		for i := range rounds[k] {
-			rounds[k][i] = Infinity
-		}
+// Backtracking pointer: how a stop was reached in a given round.
+// We need to store how we got here to reconstruct the journey.
+// The in-vehicle part is kept even when a footpath gives an earlier arrival,
+// because footpaths always start from in-vehicle arrivals. The ride is kept
+// as positions in the route, as a loop line serves some stops twice.
+type label struct {
+	boardPos  int32 // index in the route's stops where the trip was boarded
+	alightPos int32
+	routeID   int
+	headway   int // of a frequency trip, 0 if timetabled
+	boardTime int
+	arrival   int // in-vehicle arrival time
+
+	walked    bool // best arrival is a footpath from walkFrom
+	walkFrom  StopID
+	walkStart int
+}
+
+// queryState holds the per-round arrival times and backtracking labels of one query.
+// Range queries reuse the same state across departure times.
+//
+// States are pooled: the values of a stop are only those of the current
+// query once touched (see stopEpochs), and a stop not touched yet reads as
+// unreached. Reads of any stop go through arrival, writes follow touch.
+type queryState struct {
+	rounds  [][]int   // [k][stopID] -> earliest arrival time
+	onBoard [][]int   // [k][stopID] -> earliest arrival still on the vehicle
+	labels  [][]label // [k][stopID] -> how the arrival was achieved
+	marked  bitset
+	stops   stopEpochs
+
+	// Trips boarded from the walk to a source stop must leave the origin
+	// at leaveBy at the latest, Infinity but for range queries
+	sources map[StopID]int
+	leaveBy int
+
+	// Reused by runRounds
+	queue   *routeQueue
+	transit []StopID
+
+	times, boards []int // backing arrays of the views above
+	labelBuf      []label
+}
+
+// acquireQueryState returns a reset state for a search of the given number
+// of rounds. It goes back to the pool with releaseQueryState.
+func (r *Raptor) acquireQueryState(rounds int) *queryState {
+	n := len(r.Data.Stops)
+	st, _ := r.queryStates.Get().(*queryState)
+	if st == nil {
+		st = &queryState{marked: newBitset(n), queue: newRouteQueue(len(r.Data.Routes))}
 	}
+	st.times, st.rounds = roundViews(st.times, st.rounds, rounds+1, n)
+	st.boards, st.onBoard = roundViews(st.boards, st.onBoard, rounds+1, n)
+	st.labelBuf, st.labels = roundViews(st.labelBuf, st.labels, rounds+1, n)
+	st.stops.begin(n)
+	st.marked.clear()
+	st.leaveBy = Infinity
+	return st
+}
+
+func (r *Raptor) releaseQueryState(st *queryState) {
+	r.queryStates.Put(st)
+}
 
-	markedStops := make(map[StopID]bool)
+// touch makes s part of the current query, unreached in every round if it
+// was not yet.
+func (st *queryState) touch(s StopID) {
+	if !st.stops.first(s) {
+		return
+	}
+	for k := range st.rounds {
+		st.rounds[k][s] = Infinity
+		st.onBoard[k][s] = Infinity
+		st.labels[k][s] = label{}
+	}
+}
 
-	// Set initial times for source stops (round 0)
+// arrival is the earliest arrival at s in round k, Infinity if unreached.
+func (st *queryState) arrival(k int, s StopID) int {
+	if !st.stops.has(s) {
+		return Infinity
+	}
+	return st.rounds[k][s]
+}
+
+// seed sets the round 0 times for the source stops.
+func (st *queryState) seed(sourceStops map[StopID]int, departureTime int) {
+	st.sources = sourceStops
 	for stopID, walkTime := range sourceStops {
-		rounds[0][stopID] = departureTime + walkTime
-		markedStops[stopID] = true
+		st.touch(stopID)
+		if t := departureTime + walkTime; t < st.rounds[0][stopID] {
+			st.rounds[0][stopID] = t
+			st.marked.set(stopID)
+		}
 	}
+}
 
-	// Backtracking pointers: [k][stopID] -> {fromStop, tripID, boardStop}
-	// We need to store how we got here to reconstruct the journey
-	type Label struct {
-		fromStop  StopID
-		routeID   int // Changed to int to allow -1
-		tripID    TripID
-		boardTime int
+// FindRoute finds the Pareto-optimal journeys between two places, leaving
+// from at departureTime. The walks to and from the stops are part of the
+// journeys. days are the services the query can ride, from ServiceDays or
+// the calendar (see Raptor.ServiceDaysOn).
+//
+// RAPTOR round k holds the earliest arrivals using at most k trips, so every
+// round that improves the arrival at a target yields a journey that trades
+// one more transfer for an earlier arrival. The result is ordered by number
+// of transfers (ascending), which is also arrival time (descending). For
+// places that share a stop, walking all the way is one of the journeys.
+//
+// With a fare limit or OptimizeCheapest the search also keeps more expensive
+// but faster alternatives apart from cheaper ones, and with a cost model
+// slower ones that cost less (see findRouteMC).
+//
+// The search stops when ctx is done or the query goes over opts.Budget. It
+// then returns the journeys found so far, possibly none, with ctx.Err() or
+// an error wrapping ErrBudgetExceeded.
+func (r *Raptor) FindRoute(ctx context.Context, from, to Place, departureTime int, days []ServiceDay, opts RouteOptions) ([]*Journey, error) {
+	g := opts.guard(ctx)
+	if opts.fareAware() || opts.Cost != nil {
+		return opts.apply(r.findRouteMC(&from, &to, departureTime, days, opts, g)), g.err
 	}
-	labels := make([][]Label, MaxRounds+1)
-	for k := 0; k <= MaxRounds; k++ {
-		labels[k] = make([]Label, len(r.Data.Stops))
+
+	st := r.acquireQueryState(opts.rounds())
+	defer r.releaseQueryState(st)
+	st.seed(from.Stops, departureTime)
+	r.runRounds(st, days, opts, g)
+
+	journeys := r.collectJourneys(st, &from, &to, nil)
+	// Places a stop apart may be best walked between
+	if arrival, _ := st.bestTarget(0, &to); arrival != Infinity {
+		journeys = append(journeys, r.walkJourney(&from, &to, departureTime, arrival))
 	}
+	return opts.apply(paretoFilter(journeys)), g.err
+}
+
+// runRounds executes the RAPTOR rounds from the currently marked stops.
+// Arrival times already in st are kept unless improved, which is what lets
+// range queries reuse the labels of later departures. When g stops the
+// search the labels stay consistent, only short of the best arrivals.
+func (r *Raptor) runRounds(st *queryState, days []ServiceDay, opts RouteOptions, g *guard) {
+	rounds, onBoard, labels := st.rounds, st.onBoard, st.labels
+	marked, queue := st.marked, st.queue
 
 	// Algorithm Loop
-	for k := 1; k <= MaxRounds; k++ {
-		// Copy previous round best times as baseline
-		copy(rounds[k], rounds[k-1])
-		
-		// 1. Accumulate routes to process
-		routesToProcess := make(map[RouteID]StopID) // Route -> Earliest marked stop index
-		// Optimization: Build Stop->Routes map on Init
-		stopRoutes := r.buildStopRoutesIndex()
-
-		for stopID := range markedStops {
-			if rRouteIDs, ok := stopRoutes[stopID]; ok {
-				for _, rid := range rRouteIDs {
-					// Check if this stop is earlier in the route than what we have
-					// We need the index of stopID in route rid
-					// Using cached index would be better.
-					if existingStop, ok := routesToProcess[rid]; ok {
-						// we want the earliest stop in the sequence
-						if r.getStopIndex(rid, stopID) < r.getStopIndex(rid, existingStop) {
-							routesToProcess[rid] = stopID
-						}
-					} else {
-						routesToProcess[rid] = stopID
-					}
-				}
+	for k := 1; k < len(rounds) && g.check(); k++ {
+		// Previous round best times are the baseline. Untouched stops are
+		// unreached in every round.
+		for _, i := range st.stops.touched {
+			if t := rounds[k-1][i]; t < rounds[k][i] {
+				rounds[k][i] = t
+			}
+			if onBoard[k-1][i] < onBoard[k][i] {
+				onBoard[k][i] = onBoard[k-1][i]
 			}
 		}
-		
-		markedStops = make(map[StopID]bool) // Clear for next round
+
+		// 1. Accumulate routes to process, from the earliest marked stop in each
+		queue.reset()
+		st.transit = marked.appendTo(st.transit[:0])
+		for _, stopID := range st.transit {
+			for _, rs := range r.routesAt(stopID) {
+				queue.addEarliest(rs)
+			}
+		}
+
+		marked.clear() // For next round
 
 		// 2. Process Routes
-		for rid, startStopID := range routesToProcess {
-			route := r.Data.Routes[rid]
+		for _, rid := range queue.routes {
+			route := &r.Data.Routes[rid]
+			if !opts.usesRoute(route) {
+				continue
+			}
+			if !g.scan(len(route.Stops) - int(queue.pos[rid])) {
+				break
+			}
 			var currentTrip *Trip
-			var boardStop StopID
+			var offset int // added to the current trip's stop times, see earliestTrip
+			var boardPos int
 			var boardTime int
-			
-			// Iterate stops starting from startStopID
-			startIdx := r.getStopIndex(rid, startStopID)
-			for i := startIdx; i < len(route.Stops); i++ {
+
+			// Iterate stops starting from the earliest marked one
+			for i := int(queue.pos[rid]); i < len(route.Stops); i++ {
 				stopID := route.Stops[i]
-				
+				if !opts.usesStop(&r.Data.Stops[stopID]) {
+					continue
+				}
+
 				// Can we improve arrival at this stop?
-				if currentTrip != nil {
-					arrivalTime := currentTrip.StopTimes[i].Arrival
-					if arrivalTime < rounds[k][stopID] {
-						rounds[k][stopID] = arrivalTime
-						labels[k][stopID] = Label{
-							fromStop:  boardStop,
-							routeID:   int(rid),
-							tripID:    currentTrip.ID,
-							boardTime: boardTime,
+				// An earlier in-vehicle arrival is worth keeping even when a
+				// footpath already reaches the stop sooner: it may open a
+				// footpath onwards that the walk arrival cannot chain into.
+				// Riding a loop back to the boarding stop is never useful.
+				if currentTrip != nil && stopID != route.Stops[boardPos] {
+					arrivalTime := currentTrip.StopTimes[i].Arrival + offset
+					st.touch(stopID)
+					if arrivalTime < onBoard[k][stopID] {
+						onBoard[k][stopID] = arrivalTime
+						lbl := &labels[k][stopID]
+						lbl.boardPos = int32(boardPos)
+						lbl.alightPos = int32(i)
+						lbl.routeID = int(rid)
+						lbl.headway = currentTrip.Headway
+						lbl.boardTime = boardTime
+						lbl.arrival = arrivalTime
+						if arrivalTime < rounds[k][stopID] {
+							rounds[k][stopID] = arrivalTime
+							lbl.walked = false
 						}
-						markedStops[stopID] = true
+						marked.set(stopID)
 					}
 				}
 
-				// Can we board a trip here?
-				prevArrival := rounds[k-1][stopID]
-				if prevArrival < Infinity {
-					// Find earliest trip departing >= prevArrival
-					// Filter by service day
-					foundTrip := false
-					for _, trip := range route.Trips {
-						if trip.ServiceId != dayType { continue }
-						dep := trip.StopTimes[i].Departure
-						if dep >= prevArrival {
-							currentTrip = &trip
-							boardStop = stopID
-							boardTime = dep
-							foundTrip = true
-							break 
-						}
-					}
-					if !foundTrip {
-						currentTrip = nil 
-					}
+				// Can we catch an earlier trip here?
+				prevArrival := st.arrival(k-1, stopID)
+				if prevArrival == Infinity {
+					continue
+				}
+				if currentTrip != nil && prevArrival > currentTrip.StopTimes[i].Departure+offset {
+					continue
+				}
+				trip, tripOffset := earliestTrip(route, days, i, prevArrival)
+				if trip == nil {
+					continue
+				}
+				dep := trip.StopTimes[i].Departure + tripOffset
+				if st.leaveBy != Infinity && prevArrival == st.rounds[0][stopID] && dep-st.sources[stopID] > st.leaveBy {
+					continue
+				}
+				if currentTrip == nil || dep < currentTrip.StopTimes[i].Departure+offset {
+					currentTrip, offset = trip, tripOffset
+					boardPos = i
+					boardTime = dep
 				}
 			}
 		}
 
-		// 3. Process Transfers
-		transitMarked := make([]StopID, 0, len(markedStops))
-		for s := range markedStops {
-			transitMarked = append(transitMarked, s)
+		if g.err != nil {
+			break
 		}
 
-		for _, stopID := range transitMarked {
-			arrivalTime := rounds[k][stopID]
+		// 3. Process Transfers
+		// Walks start from the in-vehicle arrival times, so the result does not
+		// depend on the order in which the marked stops are visited.
+		st.transit = marked.appendTo(st.transit[:0])
+
+		for _, stopID := range st.transit {
+			arrivalTime := labels[k][stopID].arrival
 			transfers := r.Data.Transfers[stopID]
 			for _, tr := range transfers {
-				walkArr := arrivalTime + tr.TimeSeconds
+				walkTime, ok := opts.footpath(tr)
+				if !ok {
+					continue
+				}
+				walkArr := arrivalTime + walkTime
+				st.touch(tr.ToStop)
 				if walkArr < rounds[k][tr.ToStop] {
 					rounds[k][tr.ToStop] = walkArr
-					labels[k][tr.ToStop] = Label{
-						fromStop: stopID,
-						routeID:  WalkRouteID,
-						// tripID meaningless
-						boardTime: arrivalTime, // Start walk time
-					}
-					markedStops[tr.ToStop] = true
+					lbl := &labels[k][tr.ToStop]
+					lbl.walked = true
+					lbl.walkFrom = stopID
+					lbl.walkStart = arrivalTime
+					marked.set(tr.ToStop)
 				}
 			}
 		}
 
 		// Optimization: If no stops marked, break
-		if len(markedStops) == 0 {
+		if marked.empty() {
 			break
 		}
 	}
 
-	// Reconstruction
-	// Find best target stop
+	marked.clear()
+}
+
+// collectJourneys reconstructs one journey per round that strictly improves
+// the best arrival at the destination. If floor is set, a round only counts
+// when it beats floor[k], the best arrival of that round before the last run.
+func (r *Raptor) collectJourneys(st *queryState, from, to *Place, floor []int) []*Journey {
+	var journeys []*Journey
 	bestTime := Infinity
-	var bestTarget StopID
-	
-	for tStop := range targetStops {
-		for k := 1; k <= MaxRounds; k++ {
-			if rounds[k][tStop] < bestTime {
-				bestTime = rounds[k][tStop]
-				bestTarget = tStop
-			}
+	for k := 1; k < len(st.rounds); k++ {
+		roundBest, roundTarget := st.bestTarget(k, to)
+		if roundBest >= bestTime {
+			continue
+		}
+		bestTime = roundBest
+		if floor != nil && roundBest >= floor[k] {
+			continue
 		}
-	}
 
-	if bestTime == Infinity {
-		return nil
+		if j := r.reconstruct(st.rounds, st.labels, k, roundTarget, from, to); j != nil {
+			journeys = append(journeys, j)
+		}
 	}
+	return journeys
+}
 
-	// Reconstruct path
-	var legs []Leg
-	currentStop := bestTarget
-	
-	// Find the round k where the best time was achieved
-	bestK := 0
-	for k := 1; k <= MaxRounds; k++ {
-		if rounds[k][bestTarget] == bestTime {
-			bestK = k
-			break
+// bestTarget returns the earliest arrival at the destination in round k,
+// walk from the stop included, and the stop it is reached from.
+func (st *queryState) bestTarget(k int, to *Place) (int, StopID) {
+	best := Infinity
+	var target StopID
+	for tStop, walkTime := range to.Stops {
+		arrival := st.arrival(k, tStop)
+		if arrival == Infinity {
+			continue
+		}
+		if t := arrival + walkTime; t < best {
+			best = t
+			target = tStop
 		}
 	}
+	return best, target
+}
+
+// reconstruct walks the labels back from target in round k and builds the journey.
+func (r *Raptor) reconstruct(rounds [][]int, labels [][]label, bestK int, target StopID, from, to *Place) *Journey {
+	var legs []Leg
+	currentStop := target
 
 	for k := bestK; k > 0; k-- {
 		// If no improvement in this round, skip to previous
@@ -219,98 +409,152 @@ func (r *Raptor) FindRoute(sourceStops map[StopID]int, targetStops map[StopID]bo
 			continue
 		}
 
-		label := labels[k][currentStop]
-		fromStop := label.fromStop
-		
-		if label.routeID == WalkRouteID {
-			// Walk Leg
-			walkStops := []Stop{r.Data.Stops[fromStop], r.Data.Stops[currentStop]}
-			walkGeom := [][2]float64{
-				{r.Data.Stops[fromStop].Lon, r.Data.Stops[fromStop].Lat},
-				{r.Data.Stops[currentStop].Lon, r.Data.Stops[currentStop].Lat},
-			}
+		lbl := labels[k][currentStop]
 
-			leg := Leg{
-				Type:       "walk",
-				FromStop:   r.Data.Stops[fromStop],
-				ToStop:     r.Data.Stops[currentStop],
-				StartTime:  SecondsToTime(label.boardTime),
-				EndTime:    SecondsToTime(rounds[k][currentStop]),
-				Duration:   rounds[k][currentStop] - label.boardTime,
-				Stops:      walkStops,
-				Geometry:   walkGeom,
-			}
+		// Footpath taken after the trip of this round
+		if lbl.walked {
+			leg := r.walkLeg(lbl.walkFrom, currentStop, lbl.walkStart, rounds[k][currentStop])
 			legs = append([]Leg{leg}, legs...)
-			
+
 			// Update currentStop to the start of the walk
-			currentStop = fromStop
-			
-			// Now check if THIS stop was reached via Transit in the SAME round
-			// If so, we need to extract that transit leg too.
-			if rounds[k][currentStop] < rounds[k-1][currentStop] {
-				// Yes, it was updated in this round. Grab its label.
-				label = labels[k][currentStop]
-				fromStop = label.fromStop
-				
-				route := r.Data.Routes[label.routeID]
-				stopsSeq, geom := r.buildLegPath(route, fromStop, currentStop)
-				leg := Leg{
-					Type:       "transit",
-					FromStop:   r.Data.Stops[fromStop],
-					ToStop:     r.Data.Stops[currentStop],
-					StartTime:  SecondsToTime(label.boardTime),
-					EndTime:    SecondsToTime(rounds[k][currentStop]), 
-					Duration:   rounds[k][currentStop] - label.boardTime,
-					RouteCode:  route.LineCode,
-					RouteColor: route.LineColor,
-					Stops:      stopsSeq,
-					Geometry:   geom,
-				}
-				legs = append([]Leg{leg}, legs...)
-				currentStop = fromStop
+			currentStop = lbl.walkFrom
+			lbl = labels[k][currentStop]
+		}
+
+		// Transit leg of this round
+		leg := r.transitLeg(RouteID(lbl.routeID), int(lbl.boardPos), int(lbl.alightPos), lbl.boardTime, lbl.arrival, lbl.headway)
+		legs = append([]Leg{leg}, legs...)
+		currentStop = leg.FromStop.ID
+	}
+
+	if len(legs) == 0 {
+		return nil
+	}
+	return r.newJourney(r.withAccess(legs, from, to))
+}
+
+// walkLeg builds a footpath leg between two stops.
+func (r *Raptor) walkLeg(from, to StopID, start, end int) Leg {
+	a, b := &r.Data.Stops[from], &r.Data.Stops[to]
+	walkStops := []Stop{*a, *b}
+	walkGeom, _ := r.walkGeometry(a.Lat, a.Lon, b.Lat, b.Lon, false)
+
+	tr, _ := r.transfer(from, to)
+	return Leg{
+		Type:       "walk",
+		Accessible: tr.StepFree,
+		FromStop:   r.Data.Stops[from],
+		ToStop:     r.Data.Stops[to],
+		StartTime:  SecondsToTime(start),
+		EndTime:    SecondsToTime(end),
+		Duration:   end - start,
+		Stops:      walkStops,
+		Geometry:   walkGeom,
+		start:      start,
+		end:        end,
+	}
+}
+
+// transitLeg builds the in-vehicle leg on route rid between two stop
+// indexes of the route. A non-zero headway marks a frequency trip, whose
+// times are only expected.
+func (r *Raptor) transitLeg(rid RouteID, fromPos, toPos, start, end, headway int) Leg {
+	route := r.Data.Routes[rid]
+	from, to := route.Stops[fromPos], route.Stops[toPos]
+	stopsSeq, geom := r.buildLegPath(route, fromPos, toPos)
+	var frequency string
+	if headway > 0 {
+		frequency = fmt.Sprintf("every ~%d min", (headway+30)/60)
+	}
+	return Leg{
+		Type:       "transit",
+		FromStop:   r.Data.Stops[from],
+		ToStop:     r.Data.Stops[to],
+		StartTime:  SecondsToTime(start),
+		EndTime:    SecondsToTime(end),
+		Duration:   end - start,
+		RouteCode:  route.LineCode,
+		RouteColor: route.LineColor,
+		Headway:    headway,
+		Frequency:  frequency,
+		Accessible: route.Accessible && r.Data.Stops[from].Wheelchair && r.Data.Stops[to].Wheelchair,
+		Stops:      stopsSeq,
+		Geometry:   geom,
+		start:      start,
+		end:        end,
+		routeID:    rid,
+	}
+}
+
+// newJourney fills in the journey summary from its legs: wait times, transfers, fare and accessibility.
+func (r *Raptor) newJourney(legs []Leg) *Journey {
+	j := &Journey{Legs: legs}
+	j.departure = legs[0].start
+	j.arrival = legs[len(legs)-1].end
+
+	transit := 0
+	prevEnd := j.departure
+	j.Accessible = true
+	for i := range legs {
+		legs[i].WaitTime = legs[i].start - prevEnd
+		prevEnd = legs[i].end
+		j.Accessible = j.Accessible && legs[i].Accessible
+		if legs[i].Type == "transit" {
+			transit++
+		}
+	}
+	if transit > 0 {
+		j.Transfers = transit - 1
+	}
+
+	j.fare = r.priceLegs(legs)
+	j.Fare = mad(j.fare)
+
+	j.DepartureTime = SecondsToTime(j.departure)
+	j.ArrivalTime = SecondsToTime(j.arrival)
+	j.DepartureDay = serviceDayOf(j.departure)
+	j.ArrivalDay = serviceDayOf(j.arrival)
+	j.Duration = j.arrival - j.departure
+	return j
+}
+
+// paretoFilter drops journeys that are dominated on (arrival, transfers) by another one
+// and orders the rest by number of transfers.
+func paretoFilter(journeys []*Journey) []*Journey {
+	result := filterDominated(journeys, func(a, b *Journey) bool {
+		return a.arrival <= b.arrival && a.Transfers <= b.Transfers
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Transfers < result[j].Transfers
+	})
+	return result
+}
+
+// filterDominated keeps the journeys no other journey dominates. dominates(a, b)
+// reports whether a is at least as good as b on every criterion; of two equal
+// journeys the first one is kept.
+func filterDominated(journeys []*Journey, dominates func(a, b *Journey) bool) []*Journey {
+	var result []*Journey
+	for i, a := range journeys {
+		dominated := false
+		for j, b := range journeys {
+			if i == j || !dominates(b, a) {
+				continue
 			}
-		} else {
-			// Transit Leg Only
-			route := r.Data.Routes[label.routeID]
-			stopsSeq, geom := r.buildLegPath(route, fromStop, currentStop)
-
-			leg := Leg{
-				Type:       "transit",
-				FromStop:   r.Data.Stops[fromStop],
-				ToStop:     r.Data.Stops[currentStop],
-				StartTime:  SecondsToTime(label.boardTime),
-				EndTime:    SecondsToTime(rounds[k][currentStop]), 
-				Duration:   rounds[k][currentStop] - label.boardTime,
-				RouteCode:  route.LineCode,
-				RouteColor: route.LineColor,
-				Stops:      stopsSeq,
-				Geometry:   geom,
+			if j < i || !dominates(a, b) {
+				dominated = true
+				break
 			}
-			legs = append([]Leg{leg}, legs...)
-			currentStop = fromStop
+		}
+		if !dominated {
+			result = append(result, a)
 		}
 	}
-	
-	// Add initial walk if needed
-	// The `rounds[0]` initialization already accounts for initial walk time.
-	// If the `currentStop` after reconstruction is not one of the initial source stops,
-	// or if it is a source stop but the `departureTime` + `walkTime` to it
-	// is different from `rounds[0][currentStop]`, then an initial walk occurred.
-	// For now, we assume the `rounds[0]` correctly sets the starting point.
-	// If `currentStop` is not in `sourceStops`, it means the first leg was a walk from a source.
-	// This part of reconstruction is often tricky and depends on how `rounds[0]` is defined.
-	// For simplicity, we'll assume `currentStop` is now one of the initial source stops.
-	
-	return &Journey{Legs: legs}
+	return result
 }
 
-// buildLegPath returns the ordered stops and a simple polyline (lon/lat pairs) between two stops along a route.
-func (r *Raptor) buildLegPath(route Route, from StopID, to StopID) ([]Stop, [][2]float64) {
-	fromIdx := r.getStopIndex(route.ID, from)
-	toIdx := r.getStopIndex(route.ID, to)
-	if fromIdx == -1 || toIdx == -1 {
-		return nil, nil
-	}
+// buildLegPath returns the ordered stops and a simple polyline (lon/lat pairs) between two stop indexes of a route.
+func (r *Raptor) buildLegPath(route Route, fromIdx, toIdx int) ([]Stop, [][2]float64) {
 	if fromIdx > toIdx {
 		fromIdx, toIdx = toIdx, fromIdx
 	}
@@ -328,30 +572,12 @@ func (r *Raptor) buildLegPath(route Route, from StopID, to StopID) ([]Stop, [][2
 	return stops, geometry
 }
 
+// SecondsToTime formats seconds since midnight of the service day as a clock
+// time. Times on the day before or after wrap around (see Journey.ArrivalDay).
 func SecondsToTime(seconds int) string {
+	seconds -= serviceDayOf(seconds) * SecondsPerDay
 	h := seconds / 3600
 	m := (seconds % 3600) / 60
 	s := seconds % 60
 	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
 }
-
-// Helpers
-
-func (r *Raptor) buildStopRoutesIndex() map[StopID][]RouteID {
-	idx := make(map[StopID][]RouteID)
-	for _, route := range r.Data.Routes {
-		for _, s := range route.Stops {
-			idx[s] = append(idx[s], route.ID)
-		}
-	}
-	return idx
-}
-
-func (r *Raptor) getStopIndex(rid RouteID, sid StopID) int {
-	for i, s := range r.Data.Routes[rid].Stops {
-		if s == sid {
-			return i
-		}
-	}
-	return -1
-}
@@ -0,0 +1,74 @@
+package routing
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestFindRouteParetoSet(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	js := mustFind(t)(r.FindRoute(context.Background(), at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0}), 28000, ServiceDays("weekday"), RouteOptions{}))
+
+	// The direct line, and a change that arrives sooner
+	want := []struct {
+		arrival   string
+		transfers int
+		rides     string
+	}{
+		{"08:30:00", 0, "08:00:00-08:30:00 x0: L1 A->D"},
+		{"08:11:00", 1, "08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D"},
+	}
+	if len(js) != len(want) {
+		for _, j := range js {
+			t.Log(describe(j))
+		}
+		t.Fatalf("got %d journeys, want %d", len(js), len(want))
+	}
+	for i, w := range want {
+		j := js[i]
+		checkJourney(t, j)
+		if j.ArrivalTime != w.arrival || j.Transfers != w.transfers || describe(j) != w.rides {
+			t.Errorf("journey %d is %s, want %s", i, describe(j), w.rides)
+		}
+	}
+}
+
+func TestFindRouteNoJourney(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	// Nothing leaves D
+	js := mustFind(t)(r.FindRoute(context.Background(), at(map[StopID]int{stopD: 0}), at(map[StopID]int{stopA: 0}), 28000, ServiceDays("weekday"), RouteOptions{}))
+	if len(js) != 0 {
+		t.Fatalf("got %d journeys from a dead end, want none", len(js))
+	}
+}
+
+func TestFindRouteWalkOnly(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	from := at(map[StopID]int{stopA: 0, stopB: 600})
+	for _, tc := range []struct {
+		name string
+		to   Place
+		want []string
+	}{
+		// Walking by way of B beats every ride
+		{"near", at(map[StopID]int{stopB: 300, stopD: 0}), []string{"07:46:40-08:01:40 x0: walk place->place"}},
+		// Still sooner than the direct line, not than the change
+		{"far", at(map[StopID]int{stopB: 1500, stopD: 0}), []string{
+			"07:46:40-08:21:40 x0: walk place->place",
+			"08:00:00-08:11:00 x1: L2 A->B walk B->E L3 E->D",
+		}},
+	} {
+		for _, opts := range []RouteOptions{{}, {Cost: &CostModel{TransferPenalty: 600}}} {
+			js := mustFind(t)(r.FindRoute(context.Background(), from, tc.to, 28000, ServiceDays("weekday"), opts))
+			var got []string
+			for _, j := range js {
+				checkJourney(t, j)
+				got = append(got, describe(j))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("%s, cost %v: got %q, want %q", tc.name, opts.Cost != nil, got, tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package routing
+
+import (
+	"context"
+	"sort"
+)
+
+// ArriveByWindow is how long, in seconds, before the requested time an
+// arrive-by journey may arrive. An earlier one, such as the last trip of the
+// day before, does not answer the query.
+const ArriveByWindow = 3 * 3600
+
+// reverseLabel is the backtracking pointer of an arrive-by search: how the
+// latest departure from a stop continues towards the targets.
+type reverseLabel struct {
+	boardPos   int32 // index in the route's stops, see label
+	alightPos  int32
+	routeID    int
+	headway    int // of a frequency trip, 0 if timetabled
+	alightTime int
+	departure  int // in-vehicle departure time
+
+	walked  bool // best departure is a footpath to walkTo
+	walkTo  StopID
+	walkEnd int
+}
+
+// reverseState mirrors queryState with latest departures instead of earliest arrivals.
+type reverseState struct {
+	rounds  [][]int // [k][stopID] -> latest departure still reaching a target
+	onBoard [][]int // [k][stopID] -> latest departure on a vehicle
+	labels  [][]reverseLabel
+	marked  bitset
+	stops   stopEpochs
+
+	queue   *routeQueue
+	transit []StopID
+
+	times, boards []int
+	labelBuf      []reverseLabel
+}
+
+// acquireReverseState is acquireQueryState for arrive-by searches.
+func (r *Raptor) acquireReverseState(rounds int) *reverseState {
+	n := len(r.Data.Stops)
+	st, _ := r.reverseStates.Get().(*reverseState)
+	if st == nil {
+		st = &reverseState{marked: newBitset(n), queue: newRouteQueue(len(r.Data.Routes))}
+	}
+	st.times, st.rounds = roundViews(st.times, st.rounds, rounds+1, n)
+	st.boards, st.onBoard = roundViews(st.boards, st.onBoard, rounds+1, n)
+	st.labelBuf, st.labels = roundViews(st.labelBuf, st.labels, rounds+1, n)
+	st.stops.begin(n)
+	st.marked.clear()
+	return st
+}
+
+func (r *Raptor) releaseReverseState(st *reverseState) {
+	r.reverseStates.Put(st)
+}
+
+// touch makes s part of the current query, unreached in every round if it
+// was not yet.
+func (st *reverseState) touch(s StopID) {
+	if !st.stops.first(s) {
+		return
+	}
+	for k := range st.rounds {
+		st.rounds[k][s] = -Infinity
+		st.onBoard[k][s] = -Infinity
+		st.labels[k][s] = reverseLabel{}
+	}
+}
+
+// departure is the latest departure from s in round k, -Infinity if unreached.
+func (st *reverseState) departure(k int, s StopID) int {
+	if !st.stops.has(s) {
+		return -Infinity
+	}
+	return st.rounds[k][s]
+}
+
+// FindRouteArriveBy finds the journeys that reach to by arrivalTime and
+// leave from as late as possible. Journeys arriving more than ArriveByWindow
+// before arrivalTime are left out.
+//
+// It runs RAPTOR backwards in time: round k holds the latest departure from
+// each stop that still arrives in time using at most k trips. Routes are
+// scanned from their last marked stop towards the start, and footpaths are
+// followed against their direction. The result is ordered by number of transfers;
+// fares are only used to filter and order it. Like FindRoute, it returns what
+// it found so far when stopped by ctx or the budget.
+func (r *Raptor) FindRouteArriveBy(ctx context.Context, from, to Place, arrivalTime int, days []ServiceDay, opts RouteOptions) ([]*Journey, error) {
+	g := opts.guard(ctx)
+	st := r.acquireReverseState(opts.rounds())
+	defer r.releaseReverseState(st)
+	for stopID, walkTime := range to.Stops {
+		st.touch(stopID)
+		if t := arrivalTime - walkTime; t > st.rounds[0][stopID] {
+			st.rounds[0][stopID] = t
+			st.marked.set(stopID)
+		}
+	}
+
+	// A forward search may walk after its last trip, so the backward one has
+	// to allow a footpath into the targets before its first trip.
+	for stopID, walkTime := range to.Stops {
+		walkEnd := arrivalTime - walkTime
+		for _, tr := range r.inboundTransfers(stopID) {
+			walkTime, ok := opts.footpath(tr)
+			if !ok {
+				continue
+			}
+			st.touch(tr.ToStop)
+			if walkDep := walkEnd - walkTime; walkDep > st.rounds[0][tr.ToStop] {
+				st.rounds[0][tr.ToStop] = walkDep
+				st.labels[0][tr.ToStop] = reverseLabel{walked: true, walkTo: stopID, walkEnd: walkEnd}
+				st.marked.set(tr.ToStop)
+			}
+		}
+	}
+
+	r.runReverseRounds(st, days, opts, g)
+
+	var journeys []*Journey
+	bestDeparture := -Infinity
+	for k := 1; k < len(st.rounds); k++ {
+		roundBest := -Infinity
+		var roundSource StopID
+		for stopID, walkTime := range from.Stops {
+			departure := st.departure(k, stopID)
+			if departure == -Infinity {
+				continue
+			}
+			if dep := departure - walkTime; dep > roundBest {
+				roundBest = dep
+				roundSource = stopID
+			}
+		}
+		if roundBest <= bestDeparture {
+			continue
+		}
+		bestDeparture = roundBest
+
+		if j := r.reconstructReverse(st, k, roundSource, &from, &to); j != nil && j.arrival >= arrivalTime-ArriveByWindow {
+			journeys = append(journeys, j)
+		}
+	}
+
+	result := filterDominated(journeys, func(a, b *Journey) bool {
+		return a.departure >= b.departure && a.Transfers <= b.Transfers
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Transfers < result[j].Transfers
+	})
+	return opts.apply(result), g.err
+}
+
+// runReverseRounds executes the backward RAPTOR rounds from the marked stops.
+func (r *Raptor) runReverseRounds(st *reverseState, days []ServiceDay, opts RouteOptions, g *guard) {
+	rounds, onBoard, labels := st.rounds, st.onBoard, st.labels
+	marked, queue := st.marked, st.queue
+
+	for k := 1; k < len(rounds) && g.check(); k++ {
+		// Previous round best times are the baseline
+		for _, i := range st.stops.touched {
+			if t := rounds[k-1][i]; t > rounds[k][i] {
+				rounds[k][i] = t
+			}
+			if onBoard[k-1][i] > onBoard[k][i] {
+				onBoard[k][i] = onBoard[k-1][i]
+			}
+		}
+
+		// 1. Accumulate routes to process, from the latest marked stop in each
+		queue.reset()
+		st.transit = marked.appendTo(st.transit[:0])
+		for _, stopID := range st.transit {
+			for _, rs := range r.routesAt(stopID) {
+				queue.addLatest(rs)
+			}
+		}
+
+		marked.clear() // For next round
+
+		// 2. Process Routes backwards
+		for _, rid := range queue.routes {
+			route := &r.Data.Routes[rid]
+			if !opts.usesRoute(route) {
+				continue
+			}
+			if !g.scan(int(queue.pos[rid]) + 1) {
+				break
+			}
+			var currentTrip *Trip
+			var offset int // added to the current trip's stop times, see latestTrip
+			var alightPos int
+			var alightTime int
+
+			for i := int(queue.pos[rid]); i >= 0; i-- {
+				stopID := route.Stops[i]
+				if !opts.usesStop(&r.Data.Stops[stopID]) {
+					continue
+				}
+
+				// Can we leave this stop later on the current trip?
+				if currentTrip != nil && stopID != route.Stops[alightPos] {
+					departure := currentTrip.StopTimes[i].Departure + offset
+					st.touch(stopID)
+					if departure > onBoard[k][stopID] {
+						onBoard[k][stopID] = departure
+						lbl := &labels[k][stopID]
+						lbl.boardPos = int32(i)
+						lbl.alightPos = int32(alightPos)
+						lbl.routeID = int(rid)
+						lbl.headway = currentTrip.Headway
+						lbl.alightTime = alightTime
+						lbl.departure = departure
+						if departure > rounds[k][stopID] {
+							rounds[k][stopID] = departure
+							lbl.walked = false
+						}
+						marked.set(stopID)
+					}
+				}
+
+				// Can we get off here? Take the latest trip arriving in time,
+				// unless the current trip is already later.
+				latest := st.departure(k-1, stopID)
+				if latest == -Infinity {
+					continue
+				}
+				trip, tripOffset := latestTrip(route, days, i, latest)
+				if trip == nil {
+					continue
+				}
+				if arr := trip.StopTimes[i].Arrival + tripOffset; currentTrip == nil || arr > currentTrip.StopTimes[i].Arrival+offset {
+					currentTrip, offset = trip, tripOffset
+					alightPos = i
+					alightTime = arr
+				}
+			}
+		}
+
+		if g.err != nil {
+			break
+		}
+
+		// 3. Process Transfers against their direction
+		st.transit = marked.appendTo(st.transit[:0])
+
+		for _, stopID := range st.transit {
+			departure := labels[k][stopID].departure
+			for _, tr := range r.inboundTransfers(stopID) {
+				walkTime, ok := opts.footpath(tr)
+				if !ok {
+					continue
+				}
+				walkDep := departure - walkTime
+				st.touch(tr.ToStop)
+				if walkDep > rounds[k][tr.ToStop] {
+					rounds[k][tr.ToStop] = walkDep
+					lbl := &labels[k][tr.ToStop]
+					lbl.walked = true
+					lbl.walkTo = stopID
+					lbl.walkEnd = departure
+					marked.set(tr.ToStop)
+				}
+			}
+		}
+
+		if marked.empty() {
+			break
+		}
+	}
+}
+
+// reconstructReverse follows the labels forward in time from source in round k.
+func (r *Raptor) reconstructReverse(st *reverseState, bestK int, source StopID, from, to *Place) *Journey {
+	var legs []Leg
+	currentStop := source
+
+	for k := bestK; k > 0; k-- {
+		if st.rounds[k][currentStop] == st.rounds[k-1][currentStop] {
+			continue
+		}
+
+		lbl := st.labels[k][currentStop]
+
+		// Footpath taken before the trip of this round
+		if lbl.walked {
+			legs = append(legs, r.walkLeg(currentStop, lbl.walkTo, st.rounds[k][currentStop], lbl.walkEnd))
+			currentStop = lbl.walkTo
+			lbl = st.labels[k][currentStop]
+		}
+
+		leg := r.transitLeg(RouteID(lbl.routeID), int(lbl.boardPos), int(lbl.alightPos), lbl.departure, lbl.alightTime, lbl.headway)
+		legs = append(legs, leg)
+		currentStop = leg.ToStop.ID
+	}
+
+	// Final footpath into a target
+	if lbl := st.labels[0][currentStop]; lbl.walked {
+		legs = append(legs, r.walkLeg(currentStop, lbl.walkTo, st.rounds[0][currentStop], lbl.walkEnd))
+	}
+
+	if len(legs) == 0 {
+		return nil
+	}
+	return r.newJourney(r.withAccess(legs, from, to))
+}
@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindRouteArriveBy(t *testing.T) {
+	r := NewRaptor(testNetwork())
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+
+	for _, tc := range []struct {
+		arriveBy int
+		want     []string
+	}{
+		// Only the change makes it, the direct line did yesterday, far too early
+		{8*3600 + 15*60, []string{"08:00:00-08:12:40 x1: L1 A->B walk B->E L3 E->D"}},
+		// The later direct trip leaves after the change
+		{9 * 3600, []string{"08:20:00-08:50:00 x0: L1 A->D"}},
+		{8 * 3600, nil},
+	} {
+		js := mustFind(t)(r.FindRouteArriveBy(context.Background(), from, to, tc.arriveBy, ServiceDays("weekday"), RouteOptions{}))
+		if len(js) != len(tc.want) {
+			for _, j := range js {
+				t.Log(describe(j))
+			}
+			t.Fatalf("arriving by %s: got %d journeys, want %d", SecondsToTime(tc.arriveBy), len(js), len(tc.want))
+		}
+		for i, w := range tc.want {
+			checkJourney(t, js[i])
+			if got := describe(js[i]); got != w {
+				t.Errorf("arriving by %s: journey %d is %s, want %s", SecondsToTime(tc.arriveBy), i, got, w)
+			}
+		}
+	}
+}
+
+// Every journey FindRoute finds arriving in time has to be matched or beaten
+// by one leaving as late with no more transfers.
+func TestFindRouteArriveByCoversFindRoute(t *testing.T) {
+	const arriveBy = 9 * 3600
+	for seed := int64(0); seed < 40; seed++ {
+		r := NewRaptor(randomNetwork(seed, 60, 25))
+		from, to, ok := randomQuery(seed, 60)
+		if !ok {
+			continue
+		}
+		latest := mustFind(t)(r.FindRouteArriveBy(context.Background(), from, to, arriveBy, ServiceDays("weekday"), RouteOptions{}))
+		for _, j := range latest {
+			checkJourney(t, j)
+			if j.arrival > arriveBy || j.arrival < arriveBy-ArriveByWindow {
+				t.Fatalf("seed %d: %s arrives late or too early", seed, describe(j))
+			}
+		}
+		for dep := 7 * 3600; dep <= arriveBy; dep += 60 {
+			for _, j := range mustFind(t)(r.FindRoute(context.Background(), from, to, dep, ServiceDays("weekday"), RouteOptions{})) {
+				if j.arrival > arriveBy {
+					continue
+				}
+				covered := false
+				for _, l := range latest {
+					if l.departure >= j.departure && l.Transfers <= j.Transfers {
+						covered = true
+						break
+					}
+				}
+				if !covered {
+					t.Fatalf("seed %d: %s leaves later than every arrive-by journey", seed, describe(j))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package routing
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scheduleRow is one departure_time of the schedules table.
+type scheduleRow struct {
+	stopDBID int
+	secs     int
+	tripKey  string
+	headway  int // seconds between the departures it was generated with, 0 if published
+	pos      int // index in the route's stops of a keyed row, see splitVariants
+}
+
+// timepoint is a scheduled time at a route stop index.
+type timepoint struct {
+	pos  int
+	secs int
+}
+
+// headwayDeparture is a departure generated from an "every n min" range.
+type headwayDeparture struct {
+	timepoint
+	headway int
+}
+
+// scheduledTrips turns the schedule rows of one route and service day into
+// its trip table, or nil if it does not run. Rows sharing a trip key are one
+// trip, placed on the route by splitVariants. Rows without one are read as timetable columns: every departure from
+// the first scheduled stop starts a trip, and the n-th time at a later stop
+// belongs to the n-th trip when that stop lists as many times. Stops between
+// timepoints are interpolated along the segment travel times hops.
+//
+// Departures the importers generated from "every 8 min" ranges, marked with
+// their headway, become Frequencies instead of trips. Published times stay
+// trips however evenly spaced.
+func scheduledTrips(stops []int, hops []int, rows []scheduleRow, serviceID string) *TripTable {
+	var points [][]timepoint
+	var generated []headwayDeparture
+
+	// Keyed trips, in the order the rows are given
+	keyed := make(map[string]int)
+	byStop := make(map[int][]int)
+	for _, row := range rows {
+		if row.headway > 0 {
+			pos := row.pos
+			if row.tripKey == "" {
+				if pos = slices.Index(stops, row.stopDBID); pos < 0 {
+					continue
+				}
+			}
+			generated = append(generated, headwayDeparture{timepoint{pos, row.secs}, row.headway})
+			continue
+		}
+		if row.tripKey == "" {
+			byStop[row.stopDBID] = append(byStop[row.stopDBID], row.secs)
+			continue
+		}
+		t, ok := keyed[row.tripKey]
+		if !ok {
+			t = len(points)
+			keyed[row.tripKey] = t
+			points = append(points, nil)
+		}
+		points[t] = append(points[t], timepoint{row.pos, row.secs})
+	}
+	for _, tp := range points {
+		sort.Slice(tp, func(i, j int) bool { return tp[i].pos < tp[j].pos })
+	}
+
+	// Timetable columns
+	anchor := -1
+	for i, sid := range stops {
+		if len(byStop[sid]) > 0 {
+			anchor = i
+			break
+		}
+	}
+	if anchor >= 0 {
+		n := len(byStop[stops[anchor]])
+		columns := make([][]timepoint, n)
+		used := make(map[int]bool)
+		for i := anchor; i < len(stops); i++ {
+			times := byStop[stops[i]]
+			if used[stops[i]] || len(times) != n {
+				continue
+			}
+			used[stops[i]] = true
+			for j, secs := range times {
+				columns[j] = append(columns[j], timepoint{i, secs})
+			}
+		}
+		points = append(points, columns...)
+	}
+
+	var trips []Trip
+	for _, tp := range points {
+		if len(tp) == 0 {
+			continue
+		}
+		trips = append(trips, Trip{
+			ID:        TripID(len(trips)), // Local to the route and service day
+			ServiceId: serviceID,
+			StopTimes: interpolate(hops, tp),
+		})
+	}
+
+	var freqs []Frequency
+	for _, run := range headwayRuns(generated) {
+		if len(run) == 1 {
+			trips = append(trips, Trip{
+				ID:        TripID(len(trips)),
+				ServiceId: serviceID,
+				StopTimes: interpolate(hops, []timepoint{run[0].timepoint}),
+			})
+			continue
+		}
+		headway := run[0].headway
+		trip := Trip{
+			ServiceId: serviceID,
+			Headway:   headway,
+			StopTimes: interpolate(hops, []timepoint{run[0].timepoint}),
+		}
+		start := trip.StopTimes[0].Departure
+		freqs = append(freqs, Frequency{
+			Start:   start,
+			End:     start + run[len(run)-1].secs - run[0].secs,
+			Headway: headway,
+			Trip:    trip,
+		})
+	}
+
+	if len(trips) == 0 && len(freqs) == 0 {
+		return nil
+	}
+	tt := NewTripTable(trips)
+	for i := range freqs {
+		freqs[i].Trip.ID = TripID(len(trips) + i)
+	}
+	tt.Frequencies = freqs
+	return tt
+}
+
+// headwayRuns splits generated departures into runs of the same stop, each
+// departure one headway after the previous one, in time order. Repeated
+// times, as where two generated ranges meet, count once.
+func headwayRuns(departures []headwayDeparture) [][]headwayDeparture {
+	sort.Slice(departures, func(i, j int) bool {
+		if departures[i].pos != departures[j].pos {
+			return departures[i].pos < departures[j].pos
+		}
+		return departures[i].secs < departures[j].secs
+	})
+	var runs [][]headwayDeparture
+	var run []headwayDeparture
+	for _, p := range departures {
+		if n := len(run); n > 0 {
+			last := run[n-1]
+			if p.pos == last.pos && p.secs == last.secs {
+				continue
+			}
+			if p.pos != last.pos || p.headway != last.headway || p.secs-last.secs != p.headway {
+				runs = append(runs, run)
+				run = nil
+			}
+		}
+		run = append(run, p)
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// variant is a stop sequence that some trips of a line direction run, with
+// the schedule rows of those trips.
+type variant struct {
+	key   string // the stop sequence, to merge variants across service days
+	stops []int  // database stop IDs
+	hops  []int
+	rows  []scheduleRow
+}
+
+// splitVariants sorts the schedule rows of a line direction by the stop
+// sequence their trips serve, stops and hops being the line_stops pattern.
+//
+// Keyless rows cannot be told apart by trip and run the whole pattern, as do
+// keyed trips scheduled at a single stop. A keyed trip whose stops come in
+// the pattern's order runs the part of it between its first and last stop:
+// the whole line, or a short turn or depot run. Following the pattern in
+// order is what places the rows of a loop, which serve a stop twice. A trip
+// that leaves the pattern, such as on a branch, runs its own stops; they
+// all carry a time, so its hops are left at zero.
+//
+// The whole pattern, if any trip runs it, comes first; the others follow in
+// the order of their first trip.
+func splitVariants(stops, hops []int, rows []scheduleRow) []*variant {
+	full := &variant{key: stopsKey(stops), stops: stops, hops: hops}
+	variants := []*variant{full}
+	byKey := map[string]*variant{full.key: full}
+
+	trips := make(map[string][]scheduleRow)
+	var order []string
+	for _, row := range rows {
+		if row.tripKey == "" {
+			full.rows = append(full.rows, row)
+			continue
+		}
+		if _, ok := trips[row.tripKey]; !ok {
+			order = append(order, row.tripKey)
+		}
+		trips[row.tripKey] = append(trips[row.tripKey], row)
+	}
+
+	for _, key := range order {
+		trip := trips[key]
+		sortTrip(trip)
+
+		positions, ok := follow(stops, trip)
+		if len(trip) == 1 {
+			if ok {
+				trip[0].pos = positions[0]
+				full.rows = append(full.rows, trip[0])
+			}
+			continue
+		}
+		var v variant
+		if ok {
+			first, last := positions[0], positions[len(positions)-1]
+			v.stops = stops[first : last+1]
+			v.hops = append([]int{0}, hops[first+1:last+1]...)
+			for i := range trip {
+				trip[i].pos = positions[i] - first
+			}
+		} else {
+			for i := range trip {
+				v.stops = append(v.stops, trip[i].stopDBID)
+				trip[i].pos = i
+			}
+			v.hops = make([]int, len(trip))
+		}
+
+		v.key = stopsKey(v.stops)
+		existing, ok := byKey[v.key]
+		if !ok {
+			existing = &variant{key: v.key, stops: v.stops, hops: v.hops}
+			byKey[v.key] = existing
+			variants = append(variants, existing)
+		}
+		existing.rows = append(existing.rows, trip...)
+	}
+
+	kept := variants[:0]
+	for _, v := range variants {
+		if len(v.rows) > 0 {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// sortTrip orders the rows of a trip by time. A trip spanning more than half
+// a day is taken to run past midnight, its early times coming last.
+func sortTrip(trip []scheduleRow) {
+	sort.SliceStable(trip, func(i, j int) bool { return trip[i].secs < trip[j].secs })
+	if len(trip) == 0 || trip[len(trip)-1].secs-trip[0].secs <= SecondsPerDay/2 {
+		return
+	}
+	wrapped := func(r scheduleRow) int {
+		if r.secs < SecondsPerDay/2 {
+			return r.secs + SecondsPerDay
+		}
+		return r.secs
+	}
+	sort.SliceStable(trip, func(i, j int) bool { return wrapped(trip[i]) < wrapped(trip[j]) })
+}
+
+// follow places the stops of a trip, in order, on the pattern stops, taking
+// the first occurrence after the previous stop each time. It reports false if
+// the trip leaves the pattern or goes back along it.
+func follow(stops []int, trip []scheduleRow) ([]int, bool) {
+	positions := make([]int, 0, len(trip))
+	next := 0
+	for _, row := range trip {
+		i := next
+		for i < len(stops) && stops[i] != row.stopDBID {
+			i++
+		}
+		if i == len(stops) {
+			return nil, false
+		}
+		positions = append(positions, i)
+		next = i + 1
+	}
+	return positions, true
+}
+
+func stopsKey(stops []int) string {
+	var b strings.Builder
+	for i, s := range stops {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(s))
+	}
+	return b.String()
+}
+
+// interpolate fills in the stop times of a trip from its timepoints, ordered
+// by stop index. Between two timepoints the scheduled running time is shared
+// in proportion to hops; before the first and after the last, hops are used as
+// they are. Times that go back are taken to be past midnight.
+func interpolate(hops []int, points []timepoint) []StopTime {
+	times := make([]int, len(hops))
+	prev := points[0]
+	times[prev.pos] = prev.secs
+	for i := prev.pos - 1; i >= 0; i-- {
+		times[i] = times[i+1] - hops[i+1]
+	}
+	for _, p := range points[1:] {
+		for p.secs < prev.secs {
+			p.secs += SecondsPerDay
+		}
+		estimated := 0
+		for i := prev.pos + 1; i <= p.pos; i++ {
+			estimated += hops[i]
+		}
+		elapsed := 0
+		for i := prev.pos + 1; i <= p.pos; i++ {
+			elapsed += hops[i]
+			share := float64(i-prev.pos) / float64(p.pos-prev.pos)
+			if estimated > 0 {
+				share = float64(elapsed) / float64(estimated)
+			}
+			times[i] = prev.secs + int(math.Round(share*float64(p.secs-prev.secs)))
+		}
+		prev = p
+	}
+	for i := prev.pos + 1; i < len(times); i++ {
+		times[i] = times[i-1] + hops[i]
+	}
+
+	stopTimes := make([]StopTime, len(times))
+	for i, t := range times {
+		stopTimes[i] = StopTime{Arrival: t, Departure: t}
+	}
+	return stopTimes
+}
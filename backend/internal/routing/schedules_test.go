@@ -0,0 +1,139 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+)
+
+// departures returns the departures of each trip of tt from stop index i.
+func departures(tt *TripTable, i int) []int {
+	var secs []int
+	for _, tr := range tt.Trips {
+		secs = append(secs, tr.StopTimes[i].Departure)
+	}
+	return secs
+}
+
+func TestScheduledTripsTimetable(t *testing.T) {
+	stops := []int{10, 11, 12, 13, 14}
+	hops := []int{0, 60, 120, 60, 60}
+	rows := []scheduleRow{
+		// Timetable columns at 10 and 12, the second one past midnight
+		{stopDBID: 10, secs: 8 * 3600},
+		{stopDBID: 10, secs: 23*3600 + 50*60},
+		{stopDBID: 12, secs: 8*3600 + 10*60},
+		{stopDBID: 12, secs: 23*3600 + 58*60},
+		// A short turn from 11 to 13
+		{stopDBID: 11, secs: 9 * 3600, tripKey: "a"},
+		{stopDBID: 13, secs: 9*3600 + 600, tripKey: "a"},
+	}
+	vs := splitVariants(stops, hops, rows)
+	if len(vs) != 2 {
+		t.Fatalf("got %d variants, want the whole line and the short turn", len(vs))
+	}
+
+	tt := scheduledTrips(vs[0].stops, vs[0].hops, vs[0].rows, "weekday")
+	if tt == nil || len(tt.Trips) != 2 || len(tt.Frequencies) != 0 {
+		t.Fatalf("whole line: got %+v, want two trips", tt)
+	}
+	// 10 minutes from 10 to 12 shared 1:2 along the hops, then the hops as they are
+	want := []int{8 * 3600, 8*3600 + 200, 8*3600 + 600, 8*3600 + 660, 8*3600 + 720}
+	for i, st := range tt.Trips[0].StopTimes {
+		if st.Departure != want[i] {
+			t.Errorf("08:00 trip leaves stop %d at %s, want %s", i, SecondsToTime(st.Departure), SecondsToTime(want[i]))
+		}
+	}
+	if got := tt.Trips[1].StopTimes[4].Arrival; got != 24*3600 {
+		t.Errorf("23:50 trip ends at %d, want 24:00:00", got)
+	}
+
+	tt = scheduledTrips(vs[1].stops, vs[1].hops, vs[1].rows, "weekday")
+	if tt == nil || len(tt.Trips) != 1 || len(tt.Trips[0].StopTimes) != 3 {
+		t.Fatalf("short turn: got %+v, want one trip over three stops", tt)
+	}
+	if got := tt.Trips[0].StopTimes[1].Departure; got != 9*3600+400 {
+		t.Errorf("short turn passes 12 at %s, want 09:06:40", SecondsToTime(got))
+	}
+
+	if tt := scheduledTrips(stops, hops, nil, "weekday"); tt != nil {
+		t.Errorf("no rows: got %+v, want no service", tt)
+	}
+}
+
+func TestScheduledTripsFrequencies(t *testing.T) {
+	stops := []int{10, 11, 12}
+	hops := []int{0, 300, 300}
+	var rows []scheduleRow
+	// Every 8 min from 06:00 to 09:00, then every 10 min, meeting at 09:00
+	for m := 6 * 60; m <= 9*60; m += 8 {
+		rows = append(rows, scheduleRow{stopDBID: 10, secs: m * 60, headway: 480})
+	}
+	for m := 9 * 60; m <= 10*60; m += 10 {
+		rows = append(rows, scheduleRow{stopDBID: 10, secs: m * 60, headway: 600})
+	}
+	// A range of a single departure
+	rows = append(rows, scheduleRow{stopDBID: 10, secs: 22 * 3600, headway: 600})
+	// Published times, however evenly spaced
+	for m := 12 * 60; m <= 13*60; m += 15 {
+		rows = append(rows, scheduleRow{stopDBID: 10, secs: m * 60})
+	}
+
+	tt := scheduledTrips(stops, hops, rows, "weekday")
+	if tt == nil {
+		t.Fatal("no service")
+	}
+	if got, want := departures(tt, 0), []int{12 * 3600, 12*3600 + 900, 12*3600 + 1800, 12*3600 + 2700, 13 * 3600, 22 * 3600}; !slices.Equal(got, want) {
+		t.Errorf("trips leave at %v, want %v", got, want)
+	}
+	if len(tt.Frequencies) != 2 {
+		t.Fatalf("got %d frequencies, want 2", len(tt.Frequencies))
+	}
+	for i, want := range []Frequency{
+		{Start: 6 * 3600, End: 8*3600 + 56*60, Headway: 480},
+		{Start: 9 * 3600, End: 10 * 3600, Headway: 600},
+	} {
+		f := tt.Frequencies[i]
+		if f.Start != want.Start || f.End != want.End || f.Headway != want.Headway || f.Trip.Headway != want.Headway {
+			t.Errorf("frequency %d: %s-%s every %d s, want %s-%s every %d s", i,
+				SecondsToTime(f.Start), SecondsToTime(f.End), f.Headway,
+				SecondsToTime(want.Start), SecondsToTime(want.End), want.Headway)
+		}
+	}
+
+	// Boarding at the last stop at 07:00 waits half a headway
+	tr, shift := tt.earliest(2, 7*3600)
+	if tr == nil || tr.Headway != 480 || tr.StopTimes[2].Departure+shift != 7*3600+240 {
+		t.Errorf("boarding at 07:00: got %v shifted %d, want the 8 min frequency at 07:04", tr, shift)
+	}
+	// Between the frequencies and the published trips, the next trip is at noon
+	tr, shift = tt.earliest(0, 10*3600+60)
+	if tr == nil || tr.Headway != 0 || tr.StopTimes[0].Departure+shift != 12*3600 {
+		t.Errorf("boarding at 10:01: got %v shifted %d, want the 12:00 trip", tr, shift)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		hops   []int
+		points []timepoint
+		want   []int
+	}{
+		// 9 min from 1 to 3 shared 1:2, the hops as they are at the ends
+		{"between timepoints", []int{0, 60, 120, 60, 60}, []timepoint{{1, 28800}, {3, 29340}}, []int{28740, 28800, 29160, 29340, 29400}},
+		{"without hops", []int{0, 0, 0, 0}, []timepoint{{0, 3600}, {3, 3900}}, []int{3600, 3700, 3800, 3900}},
+		{"past midnight", []int{0, 100, 100}, []timepoint{{0, 86000}, {2, 200}}, []int{86000, 86300, 86600}},
+		{"last stop only", []int{0, 60, 60}, []timepoint{{2, 1000}}, []int{880, 940, 1000}},
+	} {
+		var got []int
+		for _, st := range interpolate(tc.hops, tc.points) {
+			if st.Arrival != st.Departure {
+				t.Errorf("%s: waits at a stop: %+v", tc.name, st)
+			}
+			got = append(got, st.Departure)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package routing
+
+// SecondsPerDay is the length of a service day. Stop times of trips that run
+// past midnight go beyond it, as in GTFS (25:10:00 is 01:10 the next morning).
+const SecondsPerDay = 86400
+
+// ServiceDay places the trips of a service pattern on the time axis of a
+// query: a trip of ServiceID runs at its stop times plus Offset seconds.
+type ServiceDay struct {
+	ServiceID string
+	Offset    int
+}
+
+// ServiceDays returns the service days a query on dayType can ride: the
+// previous day, whose trips may still run after midnight, the day itself, and
+// the next day, so that a late query can wait for the first morning departure.
+//
+// dayType does not say which weekday it is, so the day before a weekday is
+// taken to be a weekday and so is the day after one: Friday night continues
+// into weekday service and Monday morning sees no Sunday night trips. A
+// holiday also runs the Sunday timetable. Calendar.ServiceDays knows better
+// when the date is known.
+func ServiceDays(dayType string) []ServiceDay {
+	prev, next := "weekday", "weekday"
+	switch dayType {
+	case "saturday":
+		next = "sunday"
+	case "sunday":
+		prev = "saturday"
+	}
+	days := []ServiceDay{
+		{ServiceID: prev, Offset: -SecondsPerDay},
+		{ServiceID: dayType, Offset: 0},
+		{ServiceID: next, Offset: SecondsPerDay},
+	}
+	if dayType == HolidayService {
+		days = append(days, ServiceDay{ServiceID: "sunday", Offset: 0})
+	}
+	return days
+}
+
+// earliestTrip returns the trip of the route that leaves stop index i first
+// at or after t on any of the service days, with the offset to add to its
+// stop times: that of its day, plus the shift of a Frequency departure.
+func earliestTrip(route *Route, days []ServiceDay, i, t int) (*Trip, int) {
+	var best *Trip
+	var bestOffset int
+	for _, day := range days {
+		trip, shift := route.TripsOn(day.ServiceID).earliest(i, t-day.Offset)
+		if trip == nil {
+			continue
+		}
+		if best == nil || trip.StopTimes[i].Departure+day.Offset+shift < best.StopTimes[i].Departure+bestOffset {
+			best, bestOffset = trip, day.Offset+shift
+		}
+	}
+	return best, bestOffset
+}
+
+// latestTrip returns the trip of the route that reaches stop index i last at
+// or before t on any of the service days, with the offset to add to its stop
+// times as for earliestTrip.
+func latestTrip(route *Route, days []ServiceDay, i, t int) (*Trip, int) {
+	var best *Trip
+	var bestOffset int
+	for _, day := range days {
+		trip, shift := route.TripsOn(day.ServiceID).latest(i, t-day.Offset)
+		if trip == nil {
+			continue
+		}
+		if best == nil || trip.StopTimes[i].Arrival+day.Offset+shift > best.StopTimes[i].Arrival+bestOffset {
+			best, bestOffset = trip, day.Offset+shift
+		}
+	}
+	return best, bestOffset
+}
+
+// serviceDayOf returns the day, relative to the service day, of a time in
+// seconds since its midnight.
+func serviceDayOf(seconds int) int {
+	if seconds < 0 {
+		return -1 - (-seconds-1)/SecondsPerDay
+	}
+	return seconds / SecondsPerDay
+}
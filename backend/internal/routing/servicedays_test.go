@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseServiceTime(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"08:05:30", 8*3600 + 5*60 + 30, true},
+		{"00:00:00", 0, true},
+		{"25:10:00", 25*3600 + 10*60, true}, // 01:10 the next morning
+		{"8:05", 0, false},
+		{"08:60:00", 0, false},
+		{"1 day 01:10:00", 0, false},
+	} {
+		got, err := ParseServiceTime(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseServiceTime(%q) = %d, %v; want %d, ok %v", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRouteAcrossMidnight(t *testing.T) {
+	from, to := at(map[StopID]int{stopA: 0}), at(map[StopID]int{stopD: 0})
+
+	// Too late for today: the first trip tomorrow morning
+	r := NewRaptor(testNetwork())
+	js := mustFind(t)(r.FindRoute(context.Background(), from, to, 23*3600, ServiceDays("weekday"), RouteOptions{}))
+	if len(js) == 0 || js[0].DepartureDay != 1 || js[0].DepartureTime != "08:00:00" {
+		t.Fatalf("leaving at 23:00: got %d journeys, want tomorrow at 08:00", len(js))
+	}
+
+	// Arriving by 01:00: yesterday's last trip arrived hours too early
+	js = mustFind(t)(r.FindRouteArriveBy(context.Background(), from, to, 3600, ServiceDays("weekday"), RouteOptions{}))
+	if len(js) != 0 {
+		t.Fatalf("arriving by 01:00: got %s, want nothing", describe(js[0]))
+	}
+
+	// A trip of yesterday's service running after midnight, at 24:30
+	d := testNetwork()
+	d.Routes = append(d.Routes, testRoute(3, "N1", []StopID{stopA, stopD}, []int{24*3600 + 1800}, 1200))
+	r = NewRaptor(d)
+	js = mustFind(t)(r.FindRoute(context.Background(), from, to, 600, ServiceDays("weekday"), RouteOptions{}))
+	if len(js) == 0 || describe(js[0]) != "00:30:00-00:50:00 x0: N1 A->D" {
+		for _, j := range js {
+			t.Log(describe(j))
+		}
+		t.Fatal("leaving at 00:10: want the night trip of the day before at 00:30")
+	}
+	js = mustFind(t)(r.FindRouteArriveBy(context.Background(), from, to, 3600, ServiceDays("weekday"), RouteOptions{}))
+	if len(js) == 0 || describe(js[0]) != "00:30:00-00:50:00 x0: N1 A->D" {
+		t.Fatal("arriving by 01:00: want the night trip of the day before at 00:30")
+	}
+}
@@ -0,0 +1,200 @@
+package routing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A snapshot is RaptorData written to a file, so that the server can start
+// without querying the database. The layout is
+//
+//	magic    [8]byte  "RPTRSNAP"
+//	version  uint32   SnapshotVersion, big endian
+//	length   uint64   of the payload
+//	payload  []byte   gob-encoded snapshotData
+//	checksum uint32   CRC-32C of the payload
+//
+// The street graph is not part of it: it is loaded from its own file.
+const (
+	snapshotMagic = "RPTRSNAP"
+
+	// SnapshotVersion changes whenever snapshotData or the types in it do.
+	SnapshotVersion = 2
+)
+
+var snapshotTable = crc32.MakeTable(crc32.Castagnoli)
+
+// snapshotData is the payload of a snapshot. Trip tables are stored once and
+// referenced by index, as routes share them across seasons.
+type snapshotData struct {
+	Stops        []Stop
+	Routes       []snapshotRoute
+	Tables       []snapshotTripTable
+	Transfers    map[StopID][]Transfer
+	DBIDToStopID map[int]StopID
+	Fares        []FareRule
+	Calendar     *Calendar
+}
+
+type snapshotRoute struct {
+	Route    Route            // without Services
+	Services map[string]int32 // service ID -> index into Tables
+}
+
+type snapshotTripTable struct {
+	Trips       []Trip
+	Frequencies []Frequency
+}
+
+// WriteSnapshot writes the data as a snapshot.
+func (d *RaptorData) WriteSnapshot(w io.Writer) error {
+	payload := snapshotData{
+		Stops:        d.Stops,
+		Routes:       make([]snapshotRoute, len(d.Routes)),
+		Transfers:    d.Transfers,
+		DBIDToStopID: d.DBIDToStopID,
+		Fares:        d.Fares,
+		Calendar:     d.Calendar,
+	}
+	tables := make(map[*TripTable]int32)
+	for i, route := range d.Routes {
+		sr := snapshotRoute{Route: route, Services: make(map[string]int32, len(route.Services))}
+		sr.Route.Services = nil
+		for id, tt := range route.Services {
+			idx, ok := tables[tt]
+			if !ok {
+				idx = int32(len(payload.Tables))
+				tables[tt] = idx
+				payload.Tables = append(payload.Tables, snapshotTripTable{Trips: tt.Trips, Frequencies: tt.Frequencies})
+			}
+			sr.Services[id] = idx
+		}
+		payload.Routes[i] = sr
+	}
+
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(&payload); err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	var header [20]byte
+	copy(header[:8], snapshotMagic)
+	binary.BigEndian.PutUint32(header[8:12], SnapshotVersion)
+	binary.BigEndian.PutUint64(header[12:20], uint64(body.Len()))
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], crc32.Checksum(body.Bytes(), snapshotTable))
+
+	for _, b := range [][]byte{header[:], body.Bytes(), trailer[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSnapshot reads data written by WriteSnapshot, checking its version and checksum.
+func ReadSnapshot(r io.Reader) (*RaptorData, error) {
+	var header [20]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading snapshot header: %w", err)
+	}
+	if string(header[:8]) != snapshotMagic {
+		return nil, errors.New("not a routing snapshot")
+	}
+	if v := binary.BigEndian.Uint32(header[8:12]); v != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d, this build reads version %d", v, SnapshotVersion)
+	}
+
+	// Read up to the announced length rather than allocating it up front
+	length := binary.BigEndian.Uint64(header[12:20])
+	body, err := io.ReadAll(io.LimitReader(r, int64(min(length, 1<<62))))
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+	if uint64(len(body)) != length {
+		return nil, errors.New("snapshot truncated")
+	}
+	var trailer [4]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return nil, fmt.Errorf("reading snapshot checksum: %w", err)
+	}
+	if binary.BigEndian.Uint32(trailer[:]) != crc32.Checksum(body, snapshotTable) {
+		return nil, errors.New("snapshot checksum mismatch")
+	}
+
+	var payload snapshotData
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	d := &RaptorData{
+		Stops:        payload.Stops,
+		Routes:       make([]Route, len(payload.Routes)),
+		Transfers:    payload.Transfers,
+		DBIDToStopID: payload.DBIDToStopID,
+		Fares:        payload.Fares,
+		Calendar:     payload.Calendar,
+	}
+	if d.Transfers == nil {
+		d.Transfers = make(map[StopID][]Transfer)
+	}
+	tables := make([]*TripTable, len(payload.Tables))
+	for i, st := range payload.Tables {
+		tables[i] = NewTripTable(st.Trips)
+		tables[i].Frequencies = st.Frequencies
+	}
+	for i, sr := range payload.Routes {
+		route := sr.Route
+		route.Services = make(map[string]*TripTable, len(sr.Services))
+		for id, idx := range sr.Services {
+			if int(idx) >= len(tables) {
+				return nil, fmt.Errorf("route %d: trip table %d out of range", i, idx)
+			}
+			route.Services[id] = tables[idx]
+		}
+		d.Routes[i] = route
+	}
+	return d, nil
+}
+
+// SaveSnapshot writes the data to a snapshot file. The file is replaced
+// atomically, so a server starting meanwhile reads the old or the new one.
+func (d *RaptorData) SaveSnapshot(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := d.WriteSnapshot(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot reads a snapshot file.
+func LoadSnapshot(path string) (*RaptorData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(bufio.NewReader(f))
+}
@@ -0,0 +1,113 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// snapshotNetwork is a random network with a calendar, a Ramadan season
+// sharing trip tables, and a frequency.
+func snapshotNetwork() *RaptorData {
+	d := randomNetwork(3, 200, 40)
+	d.Calendar = DefaultCalendar()
+	d.Calendar.Holidays["2026-03-20"] = "Eid al-Fitr"
+	start, _ := ParseDate("2026-02-19")
+	end, _ := ParseDate("2026-03-19")
+	d.Calendar.Seasons = []Season{{Name: "ramadan", Start: start, End: end}}
+	d.Routes[0].Services["weekday"].Frequencies = []Frequency{{
+		Start: 6 * 3600, End: 9 * 3600, Headway: 480,
+		Trip: Trip{ID: 1000, ServiceId: "weekday", Headway: 480, StopTimes: d.Routes[0].Services["weekday"].Trips[0].StopTimes},
+	}}
+	for i := range d.Routes {
+		d.Calendar.FillSeasons(&d.Routes[i])
+	}
+	return d
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	d := snapshotNetwork()
+	path := filepath.Join(t.TempDir(), "routing.snap")
+	if err := d.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got.Stops, d.Stops) || !reflect.DeepEqual(got.Transfers, d.Transfers) ||
+		!reflect.DeepEqual(got.DBIDToStopID, d.DBIDToStopID) || !reflect.DeepEqual(got.Fares, d.Fares) {
+		t.Fatal("stops, transfers or fares differ")
+	}
+	if !slices.Equal(got.Calendar.ServiceIDs(), d.Calendar.ServiceIDs()) || got.Calendar.Holidays["2026-03-20"] != "Eid al-Fitr" {
+		t.Fatalf("calendar differs: %v", got.Calendar.ServiceIDs())
+	}
+	if len(got.Routes) != len(d.Routes) {
+		t.Fatalf("got %d routes, want %d", len(got.Routes), len(d.Routes))
+	}
+	for i := range d.Routes {
+		want, route := d.Routes[i], got.Routes[i]
+		if !slices.Equal(route.Stops, want.Stops) || route.LineCode != want.LineCode || route.FareID != want.FareID {
+			t.Fatalf("route %d differs", i)
+		}
+		for id, tt := range want.Services {
+			if !reflect.DeepEqual(route.Services[id].Trips, tt.Trips) || !reflect.DeepEqual(route.Services[id].Frequencies, tt.Frequencies) {
+				t.Fatalf("route %d: %s trips differ", i, id)
+			}
+		}
+		// Seasons share the regular tables, and still do once read back
+		if route.Services["ramadan_weekday"] != route.Services["weekday"] {
+			t.Fatalf("route %d: ramadan_weekday no longer shares the weekday table", i)
+		}
+	}
+
+	// The same network answers the same
+	before, after := NewRaptor(d), NewRaptor(got)
+	for q := int64(0); q < 20; q++ {
+		from, to, ok := randomQuery(q, len(d.Stops))
+		if !ok {
+			continue
+		}
+		want := mustFind(t)(before.FindRoute(context.Background(), from, to, 7*3600, ServiceDays("weekday"), RouteOptions{}))
+		js := mustFind(t)(after.FindRoute(context.Background(), from, to, 7*3600, ServiceDays("weekday"), RouteOptions{}))
+		if len(js) != len(want) {
+			t.Fatalf("query %d: got %d journeys, want %d", q, len(js), len(want))
+		}
+		for i := range js {
+			if describe(js[i]) != describe(want[i]) {
+				t.Fatalf("query %d: got %s, want %s", q, describe(js[i]), describe(want[i]))
+			}
+		}
+	}
+}
+
+func TestSnapshotRejectsDamage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := snapshotNetwork().WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	damage := func(f func(b []byte) []byte) []byte {
+		return f(bytes.Clone(good))
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"other file", damage(func(b []byte) []byte { return append([]byte("PK\x03\x04"), b[4:]...) }), "not a routing snapshot"},
+		{"other version", damage(func(b []byte) []byte { b[11]++; return b }), "version"},
+		{"truncated", good[:len(good)/2], "truncated"},
+		{"flipped bit", damage(func(b []byte) []byte { b[len(b)/2] ^= 1; return b }), "checksum"},
+	} {
+		if _, err := ReadSnapshot(bytes.NewReader(tc.data)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
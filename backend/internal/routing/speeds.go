@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Speeds are average commercial speeds in km/h per line type, stops included.
+// They turn distances into travel times for segments without a timetable.
+type Speeds map[string]float64
+
+// DefaultSpeeds are typical Casablanca speeds.
+var DefaultSpeeds = Speeds{
+	"tram":       17,
+	"busway":     20,
+	"bus":        14,
+	"train":      45,
+	"grand_taxi": 25,
+}
+
+// fallbackSpeed is used for line types missing from Speeds, in km/h.
+const fallbackSpeed = 15.0
+
+// detourFactor stretches straight-line distances between stops to an
+// approximate distance along the road or track.
+const detourFactor = 1.3
+
+// TravelTime estimates the seconds a vehicle of the line type needs for a
+// distance along its route.
+func (sp Speeds) TravelTime(lineType string, meters float64) int {
+	kmh, ok := sp[lineType]
+	if !ok || kmh <= 0 {
+		kmh = fallbackSpeed
+	}
+	return int(math.Round(meters / (kmh / 3.6)))
+}
+
+// StraightTravelTime estimates the seconds between two stops from their positions.
+func (sp Speeds) StraightTravelTime(lineType string, a, b *Stop) int {
+	return sp.TravelTime(lineType, detourFactor*DistanceMeters(a.Lat, a.Lon, b.Lat, b.Lon))
+}
+
+// segmentTime is the seconds from stop from to the next stop of a line: the
+// timetabled travel time, else the distance at the speed of the line type,
+// else the straight line between the stops. It is 0 with neither a time nor
+// a distance when to is unknown (nil).
+func (sp Speeds) segmentTime(lineType string, travelTime, distance *int, from, to *Stop) int {
+	switch {
+	case travelTime != nil && *travelTime > 0:
+		return *travelTime
+	case distance != nil && *distance > 0:
+		return sp.TravelTime(lineType, float64(*distance))
+	case to != nil:
+		return sp.StraightTravelTime(lineType, from, to)
+	}
+	return 0
+}
+
+// ParseSpeeds parses "tram=18,bus=12" into speeds over DefaultSpeeds.
+func ParseSpeeds(s string) (Speeds, error) {
+	sp := make(Speeds, len(DefaultSpeeds))
+	for k, v := range DefaultSpeeds {
+		sp[k] = v
+	}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		lineType, value, ok := strings.Cut(item, "=")
+		lineType = strings.TrimSpace(lineType)
+		kmh, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || lineType == "" || err != nil || kmh <= 0 || math.IsNaN(kmh) || math.IsInf(kmh, 0) {
+			return nil, fmt.Errorf("invalid speed %q: want line_type=km/h", item)
+		}
+		if seen[lineType] {
+			return nil, fmt.Errorf("speed of %s given twice", lineType)
+		}
+		seen[lineType] = true
+		sp[lineType] = kmh
+	}
+	return sp, nil
+}
@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseSpeeds(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want map[string]float64 // changes to DefaultSpeeds, nil for an error
+	}{
+		{"", map[string]float64{}},
+		{"tram=18, bus=12.5,", map[string]float64{"tram": 18, "bus": 12.5}},
+		{"metro=30", map[string]float64{"metro": 30}},
+		{"tram", nil},
+		{"tram=", nil},
+		{"=18", nil},
+		{"tram=-1", nil},
+		{"tram=0", nil},
+		{"tram=x", nil},
+		{"tram=NaN", nil},
+		{"tram=18,bus=12,tram=19", nil},
+	} {
+		got, err := ParseSpeeds(tc.in)
+		if tc.want == nil {
+			if err == nil {
+				t.Errorf("ParseSpeeds(%q) = %v, want an error", tc.in, got)
+			}
+			continue
+		}
+		want := maps.Clone(DefaultSpeeds)
+		maps.Copy(want, tc.want)
+		if err != nil || !maps.Equal(got, want) {
+			t.Errorf("ParseSpeeds(%q) = %v, %v; want %v", tc.in, got, err, want)
+		}
+	}
+}
+
+func TestSegmentTime(t *testing.T) {
+	d := testNetwork()
+	a, b := &d.Stops[stopA], &d.Stops[stopB]
+	straight := DefaultSpeeds.StraightTravelTime("bus", a, b) // 111 m apart
+	if straight != 37 {
+		t.Fatalf("straight line A-B: %d s, want 37", straight)
+	}
+	seconds := func(v int) *int { return &v }
+
+	for _, tc := range []struct {
+		name                 string
+		lineType             string
+		travelTime, distance *int
+		to                   *Stop
+		want                 int
+	}{
+		{"timetabled", "bus", seconds(120), seconds(1000), b, 120},
+		{"distance", "bus", nil, seconds(1000), b, 257},
+		{"distance without a time", "bus", seconds(0), seconds(1000), b, 257},
+		{"unknown line type", "ferry", nil, seconds(1000), b, 240},
+		{"stop positions", "bus", seconds(0), seconds(0), b, straight},
+		{"unknown stop", "bus", nil, nil, nil, 0},
+		{"unknown stop, timetabled", "bus", seconds(90), nil, nil, 90},
+	} {
+		if got := DefaultSpeeds.segmentTime(tc.lineType, tc.travelTime, tc.distance, a, tc.to); got != tc.want {
+			t.Errorf("%s: %d s, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package routing
+
+// Transfer types of the curated transfers table, as in GTFS transfers.txt.
+const (
+	TransferRecommended = 0 // a good place to change, walk it as usual
+	TransferTimed       = 1 // the departing vehicle waits for the arriving one
+	TransferMinTime     = 2 // the change needs min_transfer_time_seconds
+	TransferNotPossible = 3 // no footpath, whatever the distance
+)
+
+// TransferRule is one row of the transfers table.
+type TransferRule struct {
+	From, To     StopID
+	Type         int
+	MinTime      int // seconds, used by TransferMinTime
+	WalkDistance int // meters, 0 if unknown
+}
+
+// applyTransferRule merges a curated transfer into the generated footpaths.
+// not_possible removes the footpath and min_time sets its time, keeping its
+// distance. The other types keep the generated footpath or, for stops too far
+// apart to have one, add it with the curated walking distance at 1 m/s.
+// It reports whether the footpaths changed.
+func (d *RaptorData) applyTransferRule(rule TransferRule) bool {
+	if rule.From == rule.To {
+		return false
+	}
+	transfers := d.Transfers[rule.From]
+	idx := -1
+	for i, tr := range transfers {
+		if tr.ToStop == rule.To {
+			idx = i
+			break
+		}
+	}
+
+	if rule.Type == TransferNotPossible {
+		if idx < 0 {
+			return false
+		}
+		d.Transfers[rule.From] = append(transfers[:idx], transfers[idx+1:]...)
+		return true
+	}
+
+	tr := Transfer{
+		ToStop:   rule.To,
+		StepFree: d.Stops[rule.From].Wheelchair && d.Stops[rule.To].Wheelchair,
+	}
+	switch {
+	case idx >= 0:
+		tr.Meters = transfers[idx].Meters
+	case rule.WalkDistance > 0:
+		tr.Meters = rule.WalkDistance
+	default:
+		tr.Meters = int(DistanceMeters(d.Stops[rule.From].Lat, d.Stops[rule.From].Lon, d.Stops[rule.To].Lat, d.Stops[rule.To].Lon))
+	}
+	switch {
+	case rule.Type == TransferMinTime:
+		tr.TimeSeconds = rule.MinTime
+		tr.MinTime = true
+	case idx >= 0:
+		return false
+	default:
+		tr.TimeSeconds = tr.Meters
+	}
+
+	if idx >= 0 {
+		tr.StepFree = transfers[idx].StepFree
+		transfers[idx] = tr
+	} else {
+		d.Transfers[rule.From] = append(transfers, tr)
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestApplyTransferRule(t *testing.T) {
+	walkBE := Transfer{ToStop: stopE, TimeSeconds: 100, Meters: 100}
+	for _, tc := range []struct {
+		name    string
+		rule    TransferRule
+		changed bool
+		want    []Transfer // footpaths from rule.From afterwards
+	}{
+		{"not_possible removes the footpath", TransferRule{From: stopB, To: stopE, Type: TransferNotPossible}, true, []Transfer{}},
+		{"not_possible without a footpath", TransferRule{From: stopA, To: stopC, Type: TransferNotPossible}, false, nil},
+		{"recommended keeps the footpath", TransferRule{From: stopB, To: stopE, Type: TransferRecommended, WalkDistance: 400}, false, []Transfer{walkBE}},
+		{"timed keeps the footpath", TransferRule{From: stopB, To: stopE, Type: TransferTimed}, false, []Transfer{walkBE}},
+		{"adds a missing footpath", TransferRule{From: stopA, To: stopC, Type: TransferRecommended, WalkDistance: 250}, true,
+			[]Transfer{{ToStop: stopC, TimeSeconds: 250, Meters: 250}}},
+		{"measures a missing footpath", TransferRule{From: stopA, To: stopC, Type: TransferTimed}, true,
+			[]Transfer{{ToStop: stopC, TimeSeconds: 222, Meters: 222}}},
+		{"to the same stop", TransferRule{From: stopB, To: stopB, Type: TransferMinTime, MinTime: 120}, false, []Transfer{walkBE}},
+	} {
+		d := testNetwork()
+		if changed := d.applyTransferRule(tc.rule); changed != tc.changed {
+			t.Errorf("%s: changed %v, want %v", tc.name, changed, tc.changed)
+		}
+		if got := d.Transfers[tc.rule.From]; !slices.Equal(got, tc.want) {
+			t.Errorf("%s: footpaths %+v, want %+v", tc.name, got, tc.want)
+		}
+		// The rule only concerns one direction
+		if got := d.Transfers[stopE]; len(got) != 1 || got[0].ToStop != stopB {
+			t.Errorf("%s: footpaths from E %+v, want the walk to B", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package routing
+
+import "sort"
+
+// TripTable holds the trips of one route on one service day.
+//
+// Trips are sorted by departure from the first stop. Each stop also has its
+// own departure and arrival columns in ascending order, so boarding is a
+// binary search even on lines where a later trip overtakes an earlier one.
+type TripTable struct {
+	Trips       []Trip
+	Frequencies []Frequency // services known only by their headway
+
+	departures [][]int   // [stop index] -> departure times, ascending
+	depTrips   [][]int32 // [stop index] -> trip index of each departure
+	arrivals   [][]int   // [stop index] -> arrival times, ascending
+	arrTrips   [][]int32 // [stop index] -> trip index of each arrival
+}
+
+// NewTripTable sorts the trips of a route for one service day and builds the
+// per-stop columns. All trips must have one stop time per route stop.
+func NewTripTable(trips []Trip) *TripTable {
+	sort.SliceStable(trips, func(i, j int) bool {
+		return trips[i].StopTimes[0].Departure < trips[j].StopTimes[0].Departure
+	})
+
+	tt := &TripTable{Trips: trips}
+	if len(trips) == 0 {
+		return tt
+	}
+
+	numStops := len(trips[0].StopTimes)
+	tt.departures = make([][]int, numStops)
+	tt.depTrips = make([][]int32, numStops)
+	tt.arrivals = make([][]int, numStops)
+	tt.arrTrips = make([][]int32, numStops)
+	for i := 0; i < numStops; i++ {
+		tt.depTrips[i] = sortedColumn(trips, func(t *Trip) int { return t.StopTimes[i].Departure })
+		tt.arrTrips[i] = sortedColumn(trips, func(t *Trip) int { return t.StopTimes[i].Arrival })
+		tt.departures[i] = make([]int, len(trips))
+		tt.arrivals[i] = make([]int, len(trips))
+		for j, t := range tt.depTrips[i] {
+			tt.departures[i][j] = trips[t].StopTimes[i].Departure
+		}
+		for j, t := range tt.arrTrips[i] {
+			tt.arrivals[i][j] = trips[t].StopTimes[i].Arrival
+		}
+	}
+	return tt
+}
+
+// sortedColumn returns the trip indexes ordered by the given time, keeping
+// the table order on ties.
+func sortedColumn(trips []Trip, at func(*Trip) int) []int32 {
+	order := make([]int32, len(trips))
+	for j := range order {
+		order[j] = int32(j)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return at(&trips[order[a]]) < at(&trips[order[b]])
+	})
+	return order
+}
+
+// EarliestDeparture returns the first trip leaving stop index i at or after t, or nil.
+func (tt *TripTable) EarliestDeparture(i, t int) *Trip {
+	if tt == nil || len(tt.Trips) == 0 {
+		return nil
+	}
+	col := tt.departures[i]
+	j := sort.SearchInts(col, t)
+	if j == len(col) {
+		return nil
+	}
+	return &tt.Trips[tt.depTrips[i][j]]
+}
+
+// LatestArrival returns the last trip reaching stop index i at or before t, or nil.
+func (tt *TripTable) LatestArrival(i, t int) *Trip {
+	if tt == nil || len(tt.Trips) == 0 {
+		return nil
+	}
+	col := tt.arrivals[i]
+	j := sort.SearchInts(col, t+1) - 1
+	if j < 0 {
+		return nil
+	}
+	return &tt.Trips[tt.arrTrips[i][j]]
+}
+
+// DeparturesBetween returns the departure times from stop index i within
+// [from, to], ascending. Frequencies count once per headway.
+func (tt *TripTable) DeparturesBetween(i, from, to int) []int {
+	if tt == nil {
+		return nil
+	}
+	var deps []int
+	if len(tt.Trips) > 0 {
+		col := tt.departures[i]
+		deps = col[sort.SearchInts(col, from):sort.SearchInts(col, to+1)]
+	}
+	if len(tt.Frequencies) == 0 {
+		return deps
+	}
+	deps = append([]int(nil), deps...)
+	for _, f := range tt.Frequencies {
+		first := f.Trip.StopTimes[i].Departure
+		for t := first; t <= first+f.End-f.Start; t += f.Headway {
+			if t >= from && t <= to {
+				deps = append(deps, t)
+			}
+		}
+	}
+	sort.Ints(deps)
+	return deps
+}
+
+// Frequency is a service that leaves the first stop every Headway seconds
+// from Start to End without published departure times, as most bus and
+// busway timetables are given. Trip holds the stop times of the departure at
+// Start; a later departure runs the same times shifted.
+//
+// A rider is expected to wait half the headway, so boarding at t is taken to
+// leave at t+Headway/2, and arriving by t to mean arriving Headway/2 earlier.
+// Before the first and after the last departure the times are exact.
+type Frequency struct {
+	Start   int // first departure from the first stop, seconds since midnight
+	End     int // last departure from the first stop
+	Headway int // seconds
+	Trip    Trip
+}
+
+// board returns the departure from stop index i expected for a rider there at
+// t, as a shift of f.Trip, if the service still runs.
+func (f *Frequency) board(i, t int) (shift int, ok bool) {
+	first := f.Trip.StopTimes[i].Departure
+	last := first + f.End - f.Start
+	switch {
+	case t > last:
+		return 0, false
+	case t <= first:
+		return 0, true
+	}
+	return min(t+f.Headway/2, last) - first, true
+}
+
+// alight returns the arrival at stop index i expected for a rider who has to
+// be there by t, as a shift of f.Trip, if the service already runs.
+func (f *Frequency) alight(i, t int) (shift int, ok bool) {
+	first := f.Trip.StopTimes[i].Arrival
+	last := first + f.End - f.Start
+	switch {
+	case t < first:
+		return 0, false
+	case t >= last:
+		return last - first, true
+	}
+	return max(t-f.Headway/2, first) - first, true
+}
+
+// earliest returns the trip leaving stop index i first at or after t, from
+// the timetable or the frequencies, with the shift of its stop times.
+func (tt *TripTable) earliest(i, t int) (*Trip, int) {
+	best, bestShift := tt.EarliestDeparture(i, t), 0
+	if tt == nil {
+		return best, bestShift
+	}
+	for j := range tt.Frequencies {
+		f := &tt.Frequencies[j]
+		shift, ok := f.board(i, t)
+		if ok && (best == nil || f.Trip.StopTimes[i].Departure+shift < best.StopTimes[i].Departure+bestShift) {
+			best, bestShift = &f.Trip, shift
+		}
+	}
+	return best, bestShift
+}
+
+// latest returns the trip reaching stop index i last at or before t, from the
+// timetable or the frequencies, with the shift of its stop times.
+func (tt *TripTable) latest(i, t int) (*Trip, int) {
+	best, bestShift := tt.LatestArrival(i, t), 0
+	if tt == nil {
+		return best, bestShift
+	}
+	for j := range tt.Frequencies {
+		f := &tt.Frequencies[j]
+		shift, ok := f.alight(i, t)
+		if ok && (best == nil || f.Trip.StopTimes[i].Arrival+shift > best.StopTimes[i].Arrival+bestShift) {
+			best, bestShift = &f.Trip, shift
+		}
+	}
+	return best, bestShift
+}
+
+// TripsOn returns the trips of the route on a service day, or nil if it does not run.
+func (r *Route) TripsOn(serviceID string) *TripTable {
+	return r.Services[serviceID]
+}
@@ -0,0 +1,58 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+)
+
+func trip(id int, times ...int) Trip {
+	t := Trip{ID: TripID(id), ServiceId: "weekday"}
+	for _, s := range times {
+		t.StopTimes = append(t.StopTimes, StopTime{Arrival: s, Departure: s})
+	}
+	return t
+}
+
+func TestTripTableBoarding(t *testing.T) {
+	// The express leaves second and overtakes the slow trip
+	slow := trip(0, 8*3600, 8*3600+1800, 9*3600)
+	express := trip(1, 8*3600+600, 8*3600+1200, 8*3600+1800)
+	late := trip(2, 10*3600, 10*3600+600, 10*3600+1200)
+	tt := NewTripTable([]Trip{late, slow, express})
+
+	if tt.Trips[0].ID != slow.ID || tt.Trips[2].ID != late.ID {
+		t.Fatalf("trips not sorted by first departure: %v", tt.Trips)
+	}
+	for _, tc := range []struct {
+		name string
+		got  *Trip
+		want TripID // -1 for none
+	}{
+		{"earliest at stop 0 from 07:00", tt.EarliestDeparture(0, 7*3600), slow.ID},
+		{"earliest at stop 0 from 08:00", tt.EarliestDeparture(0, 8*3600), slow.ID},
+		{"earliest at stop 0 from 08:00:01", tt.EarliestDeparture(0, 8*3600+1), express.ID},
+		{"earliest at stop 2 from 08:00", tt.EarliestDeparture(2, 8*3600), express.ID},
+		{"earliest at stop 2 from 08:40", tt.EarliestDeparture(2, 8*3600+2400), slow.ID},
+		{"earliest at stop 2 from 10:30", tt.EarliestDeparture(2, 10*3600+1800), -1},
+		{"latest at stop 2 by 09:30", tt.LatestArrival(2, 9*3600+1800), slow.ID},
+		{"latest at stop 2 by 08:59", tt.LatestArrival(2, 9*3600-60), express.ID},
+		{"latest at stop 1 by 08:00", tt.LatestArrival(1, 8*3600), -1},
+	} {
+		got := TripID(-1)
+		if tc.got != nil {
+			got = tc.got.ID
+		}
+		if got != tc.want {
+			t.Errorf("%s: got trip %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	if got, want := tt.DeparturesBetween(1, 8*3600, 9*3600), []int{8*3600 + 1200, 8*3600 + 1800}; !slices.Equal(got, want) {
+		t.Errorf("departures from stop 1 between 08:00 and 09:00: got %v, want %v", got, want)
+	}
+
+	var none *TripTable
+	if none.EarliestDeparture(0, 0) != nil || none.LatestArrival(0, 0) != nil || none.DeparturesBetween(0, 0, SecondsPerDay) != nil {
+		t.Error("a route not running that day has trips")
+	}
+}
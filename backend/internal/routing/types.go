@@ -1,7 +1,10 @@
 package routing
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/antigravity/morocco-transport/internal/streets"
 )
 
 // RAPTOR Data Structures (optimized for memory/speed)
@@ -15,6 +18,10 @@ type RaptorData struct {
 	Routes       []Route               `json:"-"`
 	Transfers    map[StopID][]Transfer `json:"-"` // Pre-calculated walking transfers
 	DBIDToStopID map[int]StopID        `json:"-"` // Fast lookup
+	Fares        []FareRule            `json:"-"` // Single-ticket fares, indexed by Route.FareID
+	Streets      *streets.Graph        `json:"-"` // Walking network, nil to walk in straight lines
+	Calendar     *Calendar             `json:"-"` // Services by date, nil for DefaultCalendar
+	Report       *LoadReport           `json:"-"` // What the Loader read, nil for other sources
 }
 
 type Stop struct {
@@ -24,23 +31,29 @@ type Stop struct {
 	Lat  float64 `json:"lat"`
 	Lon  float64 `json:"lon"`
 	Name string  `json:"name"`
+
+	Wheelchair bool `json:"wheelchair"` // step-free boarding and alighting
 }
 
 type Route struct {
-	ID       RouteID  `json:"id"`
-	Stops    []StopID `json:"stops"` // Ordered sequence of stops
-	Trips    []Trip   `json:"trips"` // Sorted mainly by departure time of first stop (simplified)
-	LineID   int      `json:"line_id"` // DB Line ID for reference
-	LineCode string   `json:"line_code"`
-	LineType string   `json:"line_type"`
-	LineColor string  `json:"line_color"`
-	Price    float64  `json:"price"`
+	ID         RouteID               `json:"id"`
+	Stops      []StopID              `json:"stops"`   // Ordered sequence of stops
+	Services   map[string]*TripTable `json:"-"`       // Trips per service day, see TripsOn
+	LineID     int                   `json:"line_id"` // DB Line ID for reference
+	LineCode   string                `json:"line_code"`
+	LineType   string                `json:"line_type"`
+	LineColor  string                `json:"line_color"`
+	OperatorID int                   `json:"operator_id"`
+	FareID     int                   `json:"fare_id"`    // Index into RaptorData.Fares, -1 if no fare is known
+	Price      float64               `json:"price"`      // MAD, single ticket
+	Accessible bool                  `json:"accessible"` // step-free vehicles, see StepFreeVehicle
 }
 
 type Trip struct {
-	ID        TripID    `json:"id"`
+	ID        TripID     `json:"id"`
 	StopTimes []StopTime `json:"stop_times"`
-	ServiceId string    `json:"service_id"` // "weekday", "saturday", "sunday"
+	ServiceId string     `json:"service_id"`        // "weekday", "saturday", "sunday"
+	Headway   int        `json:"headway,omitempty"` // seconds, if the trip stands for a Frequency
 }
 
 type StopTime struct {
@@ -48,12 +61,41 @@ type StopTime struct {
 	Departure int `json:"departure"` // Seconds since midnight
 }
 
+// FareRule is a single-ticket fare from the fares table.
+// A ticket bought on a rule with TransferAllowed also covers boardings on other
+// transferable rules of the same operator for TransferWindow seconds, e.g. the
+// 60-minute tram<->busway transfer.
+type FareRule struct {
+	ID              int     `json:"id"` // DB fares.id
+	OperatorID      int     `json:"operator_id"`
+	LineType        string  `json:"line_type"`
+	Price           float64 `json:"price"` // MAD
+	TransferAllowed bool    `json:"transfer_allowed"`
+	TransferWindow  int     `json:"transfer_window"` // seconds from first boarding
+}
+
 type Transfer struct {
 	ToStop      StopID `json:"to_stop"`
 	TimeSeconds int    `json:"time_seconds"` // Walking time
+	Meters      int    `json:"meters"`       // Walking distance
+	MinTime     bool   `json:"min_time"`     // TimeSeconds is a curated minimum, whatever the walking speed
+	StepFree    bool   `json:"step_free"`    // usable in a wheelchair
 }
 
 // Helper to convert time.Time to seconds from midnight
 func TimeToSeconds(t time.Time) int {
 	return t.Hour()*3600 + t.Minute()*60 + t.Second()
 }
+
+// ParseServiceTime parses an HH:MM:SS time of the service day into seconds
+// since midnight. Hours may go past 23 for trips running after midnight.
+func ParseServiceTime(s string) (int, error) {
+	var h, m, sec int
+	if _, err := fmt.Sscanf(s, "%d:%d:%d", &h, &m, &sec); err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	if h < 0 || m < 0 || m > 59 || sec < 0 || sec > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*3600 + m*60 + sec, nil
+}
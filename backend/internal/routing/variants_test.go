@@ -0,0 +1,111 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSplitVariants(t *testing.T) {
+	// A loop 10 11 12 10 13
+	stops := []int{10, 11, 12, 10, 13}
+	hops := []int{0, 60, 60, 60, 60}
+	rows := []scheduleRow{
+		{stopDBID: 10, secs: 7 * 3600, tripKey: "full"},
+		{stopDBID: 11, secs: 7*3600 + 60, tripKey: "full"},
+		{stopDBID: 12, secs: 7*3600 + 120, tripKey: "full"},
+		{stopDBID: 10, secs: 7*3600 + 180, tripKey: "full"},
+		{stopDBID: 13, secs: 7*3600 + 240, tripKey: "full"},
+		// Two short turns from 11 to 12
+		{stopDBID: 11, secs: 8 * 3600, tripKey: "short"},
+		{stopDBID: 12, secs: 8*3600 + 100, tripKey: "short"},
+		{stopDBID: 11, secs: 9 * 3600, tripKey: "short2"},
+		{stopDBID: 12, secs: 9*3600 + 100, tripKey: "short2"},
+		// A branch off the line
+		{stopDBID: 10, secs: 10 * 3600, tripKey: "branch"},
+		{stopDBID: 20, secs: 10*3600 + 300, tripKey: "branch"},
+		{stopDBID: 21, secs: 10*3600 + 600, tripKey: "branch"},
+		// Past midnight, skipping the loop
+		{stopDBID: 10, secs: 23*3600 + 59*60, tripKey: "night"},
+		{stopDBID: 13, secs: 5 * 60, tripKey: "night"},
+		// A departure from the first stop
+		{stopDBID: 10, secs: 6 * 3600},
+	}
+
+	want := []struct {
+		key   string
+		hops  []int
+		first []int // departure of each trip from the first stop
+	}{
+		{"10,11,12,10,13", hops, []int{6 * 3600, 7 * 3600, 23*3600 + 59*60}},
+		{"11,12", []int{0, 60}, []int{8 * 3600, 9 * 3600}},
+		{"10,20,21", []int{0, 0, 0}, []int{10 * 3600}},
+	}
+	vs := splitVariants(stops, hops, rows)
+	if len(vs) != len(want) {
+		for _, v := range vs {
+			t.Log(v.key)
+		}
+		t.Fatalf("got %d variants, want %d", len(vs), len(want))
+	}
+	for i, w := range want {
+		v := vs[i]
+		tt := scheduledTrips(v.stops, v.hops, v.rows, "weekday")
+		if v.key != w.key || !slices.Equal(v.hops, w.hops) || tt == nil || !slices.Equal(departures(tt, 0), w.first) {
+			t.Errorf("variant %d: %s hops %v, want %s hops %v departing at %v", i, v.key, v.hops, w.key, w.hops, w.first)
+		}
+	}
+
+	// The night trip reaches 13, the last stop, the next morning past the second 10
+	night := scheduledTrips(vs[0].stops, vs[0].hops, vs[0].rows, "weekday").Trips[2]
+	if got := night.StopTimes[4].Arrival; got != SecondsPerDay+5*60 {
+		t.Errorf("night trip reaches 13 at %d, want 24:05:00", got)
+	}
+}
+
+func TestFindRouteOnLoop(t *testing.T) {
+	d := &RaptorData{Transfers: map[StopID][]Transfer{}, DBIDToStopID: map[int]StopID{}}
+	for i := 0; i < 4; i++ {
+		d.Stops = append(d.Stops, Stop{ID: StopID(i), DBID: i + 1, Name: fmt.Sprint("S", i), Lat: 33.5 + float64(i)*0.01, Lon: -7.6})
+		d.DBIDToStopID[i+1] = StopID(i)
+	}
+	// S0 S1 S2 S0 S3: S0 is served twice, S3 only past the second time
+	d.Routes = []Route{testRoute(0, "L", []StopID{0, 1, 2, 0, 3}, []int{28800}, 100)}
+	r := NewRaptor(d)
+	from, to := at(map[StopID]int{2: 0}), at(map[StopID]int{0: 0, 3: 0})
+	from3, to3 := at(map[StopID]int{2: 0}), at(map[StopID]int{3: 0})
+
+	for _, tc := range []struct {
+		name  string
+		find  func() ([]*Journey, error)
+		want  string
+		stops int // of the ride
+	}{
+		{"depart at", func() ([]*Journey, error) {
+			return r.FindRoute(context.Background(), from3, to3, 28000, ServiceDays("weekday"), RouteOptions{})
+		}, "08:03:20-08:06:40 x0: L S2->S3", 3},
+		{"arrive by", func() ([]*Journey, error) {
+			return r.FindRouteArriveBy(context.Background(), from3, to3, 30000, ServiceDays("weekday"), RouteOptions{})
+		}, "08:03:20-08:06:40 x0: L S2->S3", 3},
+		{"cheapest", func() ([]*Journey, error) {
+			return r.FindRoute(context.Background(), from3, to3, 28000, ServiceDays("weekday"), RouteOptions{Optimize: OptimizeCheapest})
+		}, "08:03:20-08:06:40 x0: L S2->S3", 3},
+		// Alighting at the second S0, not before boarding at the first
+		{"back round", func() ([]*Journey, error) {
+			return r.FindRoute(context.Background(), from, to, 28000, ServiceDays("weekday"), RouteOptions{})
+		}, "08:03:20-08:05:00 x0: L S2->S0", 2},
+	} {
+		js := mustFind(t)(tc.find())
+		if len(js) != 1 || describe(js[0]) != tc.want {
+			for _, j := range js {
+				t.Log(describe(j))
+			}
+			t.Fatalf("%s: want %s", tc.name, tc.want)
+		}
+		checkJourney(t, js[0])
+		if stops := len(js[0].Legs[0].Stops); stops != tc.stops {
+			t.Errorf("%s: ride over %d stops, want %d", tc.name, stops, tc.stops)
+		}
+	}
+}
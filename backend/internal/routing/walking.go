@@ -0,0 +1,119 @@
+package routing
+
+import (
+	"math"
+
+	"github.com/antigravity/morocco-transport/internal/streets"
+)
+
+// maxSnap is how far, in meters, a stop or a place may be from the street
+// network and still be connected to it.
+const maxSnap = 200.0
+
+// streetSnap connects a stop to its nearest street node.
+type streetSnap struct {
+	node int32
+	dist float64 // meters from the stop to the node
+	ok   bool
+}
+
+// snapStops connects every stop to the street network.
+func snapStops(g *streets.Graph, stops []Stop) []streetSnap {
+	snaps := make([]streetSnap, len(stops))
+	for i, s := range stops {
+		snaps[i].node, snaps[i].dist, snaps[i].ok = g.Nearest(s.Lat, s.Lon, maxSnap)
+	}
+	return snaps
+}
+
+// stopsByNode groups the snapped stops by street node.
+func stopsByNode(snaps []streetSnap) map[int32][]StopID {
+	byNode := make(map[int32][]StopID)
+	for i, sn := range snaps {
+		if sn.ok {
+			byNode[sn.node] = append(byNode[sn.node], StopID(i))
+		}
+	}
+	return byNode
+}
+
+// streetDistances returns the walking distance along the streets from a
+// point to every stop within maxWalk meters, and whether the walk takes steps.
+// With stepFree, only walks without steps count. ok is false if the point is
+// off the network.
+func streetDistances(g *streets.Graph, byNode map[int32][]StopID, snaps []streetSnap, lat, lon, maxWalk float64, stepFree bool, visit func(s StopID, meters float64, steps bool)) bool {
+	node, dist, ok := g.Nearest(lat, lon, maxSnap)
+	if !ok {
+		return false
+	}
+	g.Reach([]streets.Start{{Node: node, Dist: dist}}, maxWalk, stepFree, func(n int32, d float64, steps bool) {
+		for _, s := range byNode[n] {
+			if total := d + snaps[s].dist; total <= maxWalk {
+				visit(s, total, steps)
+			}
+		}
+	})
+	return true
+}
+
+// StreetTransfers builds the footpaths between stops within maxWalk meters of
+// each other along the street network, walked at 1 m/s like the generated
+// ones. A footpath is step-free when both stops are accessible and the walk
+// takes no steps. Stops off the network get no footpaths.
+func (d *RaptorData) StreetTransfers(maxWalk float64) int {
+	snaps := snapStops(d.Streets, d.Stops)
+	byNode := stopsByNode(snaps)
+	count := 0
+	for i := range d.Stops {
+		from := &d.Stops[i]
+		if !snaps[i].ok {
+			continue
+		}
+		streetDistances(d.Streets, byNode, snaps, from.Lat, from.Lon, maxWalk, false, func(to StopID, meters float64, steps bool) {
+			if to == from.ID {
+				return
+			}
+			d.Transfers[from.ID] = append(d.Transfers[from.ID], Transfer{
+				ToStop:      to,
+				TimeSeconds: int(meters),
+				Meters:      int(meters),
+				StepFree:    from.Wheelchair && d.Stops[to].Wheelchair && !steps,
+			})
+			count++
+		})
+	}
+	return count
+}
+
+// walkGeometry is the line a walk between two points follows, without steps
+// if stepFree, and whether it takes steps: the streets when the network
+// connects them, a straight line otherwise, taken to be step-free.
+func (r *Raptor) walkGeometry(fromLat, fromLon, toLat, toLon float64, stepFree bool) ([][2]float64, bool) {
+	straight := [][2]float64{{fromLon, fromLat}, {toLon, toLat}}
+	g := r.Data.Streets
+	if g == nil {
+		return straight, false
+	}
+	a, _, okA := g.Nearest(fromLat, fromLon, maxSnap)
+	b, _, okB := g.Nearest(toLat, toLon, maxSnap)
+	if !okA || !okB {
+		return straight, false
+	}
+	limit := 3*DistanceMeters(fromLat, fromLon, toLat, toLon) + 2*maxSnap
+	path, _, steps, ok := g.Path(a, b, limit, stepFree)
+	if !ok {
+		return straight, false
+	}
+	geom := make([][2]float64, 0, len(path)+2)
+	for _, pt := range append(append([][2]float64{straight[0]}, path...), straight[1]) {
+		if len(geom) == 0 || geom[len(geom)-1] != pt {
+			geom = append(geom, pt)
+		}
+	}
+	return geom, steps
+}
+
+// walkSeconds is the time to walk a distance at speed m/s.
+func walkSeconds(meters, speed float64) int {
+	return int(math.Ceil(meters / speed))
+}
@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antigravity/morocco-transport/internal/streets"
+)
+
+// osmWay is a way of a test extract: its highway tag and its nodes as
+// [lat, lon].
+type osmWay struct {
+	highway string
+	nodes   [][2]float64
+}
+
+// streetGraph writes the ways to an OSM XML extract and loads it.
+func streetGraph(t *testing.T, ways ...osmWay) *streets.Graph {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><osm>`)
+	ids := map[[2]float64]int{}
+	var refs [][]int
+	for _, w := range ways {
+		var nds []int
+		for _, c := range w.nodes {
+			if _, ok := ids[c]; !ok {
+				ids[c] = len(ids) + 1
+				fmt.Fprintf(&b, `<node id="%d" lat="%f" lon="%f"/>`, ids[c], c[0], c[1])
+			}
+			nds = append(nds, ids[c])
+		}
+		refs = append(refs, nds)
+	}
+	for i, w := range ways {
+		fmt.Fprintf(&b, `<way id="%d">`, i+1)
+		for _, ref := range refs[i] {
+			fmt.Fprintf(&b, `<nd ref="%d"/>`, ref)
+		}
+		fmt.Fprintf(&b, `<tag k="highway" v="%s"/></way>`, w.highway)
+	}
+	b.WriteString(`</osm>`)
+
+	path := filepath.Join(t.TempDir(), "streets.osm")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := streets.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// stopAt is the street node at the stop of testNetwork.
+func stopAt(s StopID) [2]float64 {
+	return [2]float64{33.5 + float64(s)*0.001, -7.6}
+}
+
+func TestPlaceWheelchairKeepsOffSteps(t *testing.T) {
+	// The street past the stops of testNetwork, about 111 m apart, has
+	// steps between B and C
+	street := []osmWay{
+		{"residential", [][2]float64{stopAt(stopA), stopAt(stopB)}},
+		{"steps", [][2]float64{stopAt(stopB), stopAt(stopC)}},
+		{"residential", [][2]float64{stopAt(stopC), stopAt(stopD), stopAt(stopE)}},
+	}
+	d := testNetwork()
+	d.Streets = streetGraph(t, street...)
+	r := NewRaptor(d)
+	origin := stopAt(stopA)
+
+	p := r.PlaceFor("origin", origin[0], origin[1], RouteOptions{})
+	if len(p.Stops) != 5 {
+		t.Fatalf("walking: reached %v, want every stop", p.Stops)
+	}
+	if leg := r.placeWalkLeg(p.stop(), d.Stops[stopC], 0, p.Stops[stopC], p.stepFree); leg.Accessible {
+		t.Error("the walk to C takes the steps but is accessible")
+	}
+	if leg := r.placeWalkLeg(p.stop(), d.Stops[stopB], 0, p.Stops[stopB], p.stepFree); !leg.Accessible {
+		t.Error("the walk to B takes no steps but is not accessible")
+	}
+
+	wp := r.PlaceFor("origin", origin[0], origin[1], RouteOptions{Wheelchair: true})
+	if len(wp.Stops) != 2 || wp.Stops[stopA] != p.Stops[stopA] || wp.Stops[stopB] != p.Stops[stopB] {
+		t.Fatalf("in a wheelchair: reached %v, want only A and B, past them the steps", wp.Stops)
+	}
+
+	// A ramp around the steps, about twice as long
+	ramp := osmWay{"footway", [][2]float64{stopAt(stopB), {33.5015, -7.599}, stopAt(stopC)}}
+	d.Streets = streetGraph(t, append(street, ramp)...)
+	r = NewRaptor(d)
+	p = r.PlaceFor("origin", origin[0], origin[1], RouteOptions{})
+	wp = r.PlaceFor("origin", origin[0], origin[1], RouteOptions{Wheelchair: true})
+	if len(wp.Stops) != 5 || wp.Stops[stopC] <= p.Stops[stopC] {
+		t.Fatalf("in a wheelchair: reached %v, want every stop, C the long way (walking %v)", wp.Stops, p.Stops)
+	}
+	leg := r.placeWalkLeg(wp.stop(), d.Stops[stopC], 0, wp.Stops[stopC], wp.stepFree)
+	if !leg.Accessible || len(leg.Geometry) < 4 {
+		t.Errorf("in a wheelchair, the walk to C is accessible %v along %v, want the ramp", leg.Accessible, leg.Geometry)
+	}
+}
@@ -0,0 +1,242 @@
+// Package streets is the pedestrian street network used for walking legs:
+// footpaths between stops and the walks to and from the rider's origin and
+// destination.
+package streets
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+)
+
+// Graph is an undirected walking network stored as flat adjacency arrays.
+// Edge i of node n is at edges[start[n]+i].
+type Graph struct {
+	lat, lon []float64
+	start    []int32
+	edges    []edge
+
+	grid map[cell][]int32 // nodes by grid cell, for snapping
+
+	searches sync.Pool // *search
+}
+
+type edge struct {
+	to     int32
+	length float32 // meters
+	steps  bool    // stairs, not usable in a wheelchair
+}
+
+// cellSize is the grid cell edge in degrees, about 220 m of latitude.
+const cellSize = 0.002
+
+type cell struct{ x, y int32 }
+
+func cellOf(lat, lon float64) cell {
+	return cell{int32(math.Floor(lon / cellSize)), int32(math.Floor(lat / cellSize))}
+}
+
+// builder accumulates nodes and segments before the graph is frozen.
+type builder struct {
+	lat, lon []float64
+	adj      [][]edge
+}
+
+func (b *builder) node(lat, lon float64) int32 {
+	b.lat = append(b.lat, lat)
+	b.lon = append(b.lon, lon)
+	b.adj = append(b.adj, nil)
+	return int32(len(b.lat) - 1)
+}
+
+func (b *builder) link(u, v int32, steps bool) {
+	if u == v {
+		return
+	}
+	l := float32(DistanceMeters(b.lat[u], b.lon[u], b.lat[v], b.lon[v]))
+	b.adj[u] = append(b.adj[u], edge{to: v, length: l, steps: steps})
+	b.adj[v] = append(b.adj[v], edge{to: u, length: l, steps: steps})
+}
+
+func (b *builder) graph() *Graph {
+	g := &Graph{
+		lat:   b.lat,
+		lon:   b.lon,
+		start: make([]int32, len(b.lat)+1),
+		grid:  make(map[cell][]int32),
+	}
+	for n, es := range b.adj {
+		g.start[n+1] = g.start[n] + int32(len(es))
+		g.edges = append(g.edges, es...)
+		if len(es) > 0 {
+			c := cellOf(g.lat[n], g.lon[n])
+			g.grid[c] = append(g.grid[c], int32(n))
+		}
+	}
+	return g
+}
+
+// NumNodes returns the number of street nodes.
+func (g *Graph) NumNodes() int {
+	return len(g.lat)
+}
+
+// Nearest returns the street node closest to a point and its distance, if
+// one lies within maxMeters.
+func (g *Graph) Nearest(lat, lon, maxMeters float64) (node int32, dist float64, ok bool) {
+	c := cellOf(lat, lon)
+	reach := int32(math.Ceil(maxMeters/(cellSize*111320*math.Cos(lat*math.Pi/180)))) + 1
+	dist = math.Inf(1)
+	for x := c.x - reach; x <= c.x+reach; x++ {
+		for y := c.y - reach; y <= c.y+reach; y++ {
+			for _, n := range g.grid[cell{x, y}] {
+				if d := DistanceMeters(lat, lon, g.lat[n], g.lon[n]); d < dist {
+					node, dist = n, d
+				}
+			}
+		}
+	}
+	return node, dist, dist <= maxMeters
+}
+
+// Start is a node a search begins from, already Dist meters into the walk.
+type Start struct {
+	Node int32
+	Dist float64
+}
+
+// Reach runs a shortest-path search from the starts and calls visit for every
+// node within maxMeters, in order of distance, with whether its shortest path
+// takes steps. With stepFree, the search keeps off steps altogether.
+func (g *Graph) Reach(starts []Start, maxMeters float64, stepFree bool, visit func(node int32, dist float64, steps bool)) {
+	s := g.search()
+	defer g.release(s)
+	s.run(g, starts, maxMeters, stepFree, -1, visit)
+}
+
+// Path returns the shortest walk between two nodes as coordinates, with its
+// length and whether it takes steps, or with stepFree the shortest walk
+// without steps. ok is false if to is more than maxMeters away.
+func (g *Graph) Path(from, to int32, maxMeters float64, stepFree bool) (coords [][2]float64, length float64, steps bool, ok bool) {
+	s := g.search()
+	defer g.release(s)
+	s.run(g, []Start{{Node: from}}, maxMeters, stepFree, to, nil)
+	if math.IsInf(s.dist[to], 1) {
+		return nil, 0, false, false
+	}
+	for n := to; n >= 0; n = s.parent[n] {
+		coords = append(coords, [2]float64{g.lon[n], g.lat[n]})
+	}
+	for i, j := 0, len(coords)-1; i < j; i, j = i+1, j-1 {
+		coords[i], coords[j] = coords[j], coords[i]
+	}
+	return coords, s.dist[to], s.steps[to], true
+}
+
+// search is the reusable state of one shortest-path search. Only the nodes
+// it touched are reset afterwards.
+type search struct {
+	dist    []float64
+	parent  []int32
+	steps   []bool
+	touched []int32
+	queue   nodeQueue
+}
+
+func (g *Graph) search() *search {
+	if s, ok := g.searches.Get().(*search); ok {
+		return s
+	}
+	s := &search{
+		dist:   make([]float64, len(g.lat)),
+		parent: make([]int32, len(g.lat)),
+		steps:  make([]bool, len(g.lat)),
+	}
+	for i := range s.dist {
+		s.dist[i] = math.Inf(1)
+		s.parent[i] = -1
+	}
+	return s
+}
+
+func (g *Graph) release(s *search) {
+	for _, n := range s.touched {
+		s.dist[n] = math.Inf(1)
+		s.parent[n] = -1
+		s.steps[n] = false
+	}
+	s.touched = s.touched[:0]
+	s.queue = s.queue[:0]
+	g.searches.Put(s)
+}
+
+// run is Dijkstra's algorithm bounded by maxMeters, stopping early once
+// target (if not -1) is settled, and left off the steps if stepFree.
+func (s *search) run(g *Graph, starts []Start, maxMeters float64, stepFree bool, target int32, visit func(int32, float64, bool)) {
+	for _, st := range starts {
+		if st.Dist < s.dist[st.Node] && st.Dist <= maxMeters {
+			if math.IsInf(s.dist[st.Node], 1) {
+				s.touched = append(s.touched, st.Node)
+			}
+			s.dist[st.Node] = st.Dist
+			heap.Push(&s.queue, queued{st.Node, st.Dist})
+		}
+	}
+	for s.queue.Len() > 0 {
+		q := heap.Pop(&s.queue).(queued)
+		if q.dist > s.dist[q.node] {
+			continue // stale entry
+		}
+		if visit != nil {
+			visit(q.node, q.dist, s.steps[q.node])
+		}
+		if q.node == target {
+			return
+		}
+		for _, e := range g.edges[g.start[q.node]:g.start[q.node+1]] {
+			if stepFree && e.steps {
+				continue
+			}
+			d := q.dist + float64(e.length)
+			if d > maxMeters || d >= s.dist[e.to] {
+				continue
+			}
+			if math.IsInf(s.dist[e.to], 1) {
+				s.touched = append(s.touched, e.to)
+			}
+			s.dist[e.to] = d
+			s.parent[e.to] = q.node
+			s.steps[e.to] = s.steps[q.node] || e.steps
+			heap.Push(&s.queue, queued{e.to, d})
+		}
+	}
+}
+
+type queued struct {
+	node int32
+	dist float64
+}
+
+type nodeQueue []queued
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queued)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// DistanceMeters returns the great-circle distance between two points.
+func DistanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
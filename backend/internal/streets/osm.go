@@ -0,0 +1,206 @@
+package streets
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkableHighways are the highway values a pedestrian can use. Motorways and
+// trunk roads are left out: in Casablanca they are the urban expressways.
+var walkableHighways = map[string]bool{
+	"footway": true, "pedestrian": true, "path": true, "steps": true,
+	"living_street": true, "residential": true, "service": true, "unclassified": true,
+	"track": true, "corridor": true, "platform": true,
+	"tertiary": true, "tertiary_link": true,
+	"secondary": true, "secondary_link": true,
+	"primary": true, "primary_link": true,
+}
+
+// walkable reports whether a way with these tags can be walked.
+func walkable(tags map[string]string) bool {
+	switch tags["foot"] {
+	case "yes", "designated", "permissive":
+		return true
+	case "no", "private":
+		return false
+	}
+	if tags["access"] == "no" || tags["access"] == "private" {
+		return false
+	}
+	return walkableHighways[tags["highway"]] ||
+		tags["railway"] == "platform" || tags["public_transport"] == "platform"
+}
+
+// osmNode and osmWay are the elements of an extract, whatever its format.
+type osmNode struct {
+	ID       int64
+	Lat, Lon float64
+}
+
+type osmWay struct {
+	Nodes []int64
+	Tags  map[string]string
+}
+
+// Load builds the walking network from a local OSM extract: OSM XML (.osm,
+// .xml), PBF (.pbf, zlib-packed as Geofabrik and osmium write them) or
+// Overpass JSON (.json) with "out body" ways and nodes, the format the
+// scrapers fetch.
+func Load(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nodes []osmNode
+	var ways []osmWay
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".osm", ".xml":
+		nodes, ways, err = readXML(f)
+	case ".json":
+		nodes, ways, err = readOverpass(f)
+	case ".pbf":
+		nodes, ways, err = readPBF(f)
+	default:
+		return nil, fmt.Errorf("%s: unknown extract format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return build(nodes, ways), nil
+}
+
+// build keeps the walkable ways and the nodes they use.
+func build(nodes []osmNode, ways []osmWay) *Graph {
+	coords := make(map[int64][2]float64, len(nodes))
+	for _, n := range nodes {
+		coords[n.ID] = [2]float64{n.Lat, n.Lon}
+	}
+
+	var b builder
+	ids := make(map[int64]int32)
+	nodeOf := func(osmID int64) (int32, bool) {
+		if id, ok := ids[osmID]; ok {
+			return id, true
+		}
+		c, ok := coords[osmID]
+		if !ok {
+			return 0, false // outside the extract
+		}
+		id := b.node(c[0], c[1])
+		ids[osmID] = id
+		return id, true
+	}
+
+	for _, w := range ways {
+		if !walkable(w.Tags) {
+			continue
+		}
+		steps := w.Tags["highway"] == "steps"
+		prev, havePrev := int32(0), false
+		for _, ref := range w.Nodes {
+			n, ok := nodeOf(ref)
+			if !ok {
+				havePrev = false
+				continue
+			}
+			if havePrev {
+				b.link(prev, n, steps)
+			}
+			prev, havePrev = n, true
+		}
+	}
+	return b.graph()
+}
+
+// readXML streams an OSM XML file.
+func readXML(r io.Reader) ([]osmNode, []osmWay, error) {
+	type tag struct {
+		K string `xml:"k,attr"`
+		V string `xml:"v,attr"`
+	}
+	type way struct {
+		Nds []struct {
+			Ref int64 `xml:"ref,attr"`
+		} `xml:"nd"`
+		Tags []tag `xml:"tag"`
+	}
+	type node struct {
+		ID  int64   `xml:"id,attr"`
+		Lat float64 `xml:"lat,attr"`
+		Lon float64 `xml:"lon,attr"`
+	}
+
+	var nodes []osmNode
+	var ways []osmWay
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nodes, ways, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "node":
+			var n node
+			if err := dec.DecodeElement(&n, &start); err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, osmNode{ID: n.ID, Lat: n.Lat, Lon: n.Lon})
+		case "way":
+			var w way
+			if err := dec.DecodeElement(&w, &start); err != nil {
+				return nil, nil, err
+			}
+			ow := osmWay{Tags: make(map[string]string, len(w.Tags))}
+			for _, t := range w.Tags {
+				ow.Tags[t.K] = t.V
+			}
+			for _, nd := range w.Nds {
+				ow.Nodes = append(ow.Nodes, nd.Ref)
+			}
+			ways = append(ways, ow)
+		}
+	}
+}
+
+// readOverpass reads an Overpass API JSON response.
+func readOverpass(r io.Reader) ([]osmNode, []osmWay, error) {
+	var resp struct {
+		Elements []struct {
+			Type  string            `json:"type"`
+			ID    int64             `json:"id"`
+			Lat   float64           `json:"lat,omitempty"`
+			Lon   float64           `json:"lon,omitempty"`
+			Nodes []int64           `json:"nodes,omitempty"`
+			Tags  map[string]string `json:"tags,omitempty"`
+		} `json:"elements"`
+	}
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return nil, nil, err
+	}
+
+	var nodes []osmNode
+	var ways []osmWay
+	for _, e := range resp.Elements {
+		switch e.Type {
+		case "node":
+			nodes = append(nodes, osmNode{ID: e.ID, Lat: e.Lat, Lon: e.Lon})
+		case "way":
+			ways = append(ways, osmWay{Nodes: e.Nodes, Tags: e.Tags})
+		}
+	}
+	return nodes, ways, nil
+}
@@ -0,0 +1,223 @@
+package streets
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testExtract is a street with steps in the middle, a footway around them
+// and a motorway crossing, as nodes (id -> lat, lon) and ways.
+var testExtract = struct {
+	nodes map[int64][2]float64
+	ways  []osmWay
+}{
+	nodes: map[int64][2]float64{
+		101: {33.5000, -7.6000},
+		102: {33.5010, -7.6000},
+		103: {33.5020, -7.6000},
+		104: {33.5030, -7.6000},
+		105: {33.5015, -7.5990},
+		106: {33.5015, -7.6100},
+	},
+	ways: []osmWay{
+		{Nodes: []int64{101, 102}, Tags: map[string]string{"highway": "residential"}},
+		{Nodes: []int64{102, 103}, Tags: map[string]string{"highway": "steps"}},
+		{Nodes: []int64{103, 104}, Tags: map[string]string{"highway": "residential", "name": "Rue 7"}},
+		{Nodes: []int64{102, 105, 103}, Tags: map[string]string{"highway": "footway"}},
+		{Nodes: []int64{105, 106}, Tags: map[string]string{"highway": "motorway"}},
+	},
+}
+
+func TestLoadFormats(t *testing.T) {
+	dir := t.TempDir()
+	xmlPath := filepath.Join(dir, "streets.osm")
+	pbfPath := filepath.Join(dir, "streets.osm.pbf")
+	if err := os.WriteFile(xmlPath, osmXML(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pbfPath, osmPBF(t, "OsmSchema-V0.6", "DenseNodes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fromXML, err := Load(xmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPBF, err := Load(pbfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The motorway is left out, and with it node 106
+	if fromXML.NumNodes() != 5 || fromPBF.NumNodes() != 5 {
+		t.Fatalf("got %d nodes from XML and %d from PBF, want 5", fromXML.NumNodes(), fromPBF.NumNodes())
+	}
+
+	for _, g := range []*Graph{fromXML, fromPBF} {
+		from, _, ok := g.Nearest(33.5000, -7.6000, 10)
+		to, _, ok2 := g.Nearest(33.5030, -7.6000, 10)
+		if !ok || !ok2 {
+			t.Fatal("street ends not found")
+		}
+		_, length, steps, ok := g.Path(from, to, 1000, false)
+		if !ok || !steps || math.Abs(length-333.6) > 1 {
+			t.Errorf("shortest walk: %.1f m, steps %v, found %v; want 333.6 m up the steps", length, steps, ok)
+		}
+		coords, stepFreeLength, steps, ok := g.Path(from, to, 1000, true)
+		if !ok || steps || len(coords) != 5 || stepFreeLength <= length {
+			t.Errorf("step-free walk: %v, %.1f m, steps %v; want it around the steps", coords, stepFreeLength, steps)
+		}
+	}
+}
+
+func TestLoadPBFUnsupportedFeature(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.osh.pbf")
+	if err := os.WriteFile(path, osmPBF(t, "OsmSchema-V0.6", "HistoricalInformation"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "HistoricalInformation") {
+		t.Fatalf("got error %v, want the unsupported feature", err)
+	}
+}
+
+func osmXML() []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><osm>`)
+	for id := int64(101); id <= 106; id++ {
+		c := testExtract.nodes[id]
+		fmt.Fprintf(&b, `<node id="%d" lat="%f" lon="%f"/>`, id, c[0], c[1])
+	}
+	for i, w := range testExtract.ways {
+		fmt.Fprintf(&b, `<way id="%d">`, i+1)
+		for _, ref := range w.Nodes {
+			fmt.Fprintf(&b, `<nd ref="%d"/>`, ref)
+		}
+		for k, v := range w.Tags {
+			fmt.Fprintf(&b, `<tag k="%s" v="%s"/>`, k, v)
+		}
+		b.WriteString(`</way>`)
+	}
+	b.WriteString(`</osm>`)
+	return []byte(b.String())
+}
+
+// osmPBF encodes testExtract as PBF: a raw header blob requiring features,
+// then a zlib-packed data blob with node 101 as a plain node, the others as
+// dense nodes, and the ways.
+func osmPBF(t *testing.T, features ...string) []byte {
+	var header []byte
+	for _, f := range features {
+		header = pbBytes(header, 4, []byte(f))
+	}
+
+	strs := []string{""}
+	index := map[string]uint64{}
+	str := func(s string) uint64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = uint64(len(strs))
+		strs = append(strs, s)
+		return index[s]
+	}
+	coord := func(deg float64) int64 { return int64(math.Round(deg * 1e7)) } // granularity 100
+
+	c := testExtract.nodes[101]
+	node := pbVarint(nil, 1, zz(101))
+	node = pbVarint(node, 8, zz(coord(c[0])))
+	node = pbVarint(node, 9, zz(coord(c[1])))
+	nodes := pbBytes(nil, 1, node)
+
+	var ids, lats, lons []uint64
+	var prevID, prevLat, prevLon int64
+	for id := int64(102); id <= 106; id++ {
+		c := testExtract.nodes[id]
+		lat, lon := coord(c[0]), coord(c[1])
+		ids, lats, lons = append(ids, zz(id-prevID)), append(lats, zz(lat-prevLat)), append(lons, zz(lon-prevLon))
+		prevID, prevLat, prevLon = id, lat, lon
+	}
+	dense := pbPacked(nil, 1, ids)
+	dense = pbPacked(dense, 8, lats)
+	dense = pbPacked(dense, 9, lons)
+	denseGroup := pbBytes(nil, 2, dense)
+
+	var ways []byte
+	for i, w := range testExtract.ways {
+		way := pbVarint(nil, 1, uint64(i+1))
+		var keys, vals, refs []uint64
+		for k, v := range w.Tags {
+			keys, vals = append(keys, str(k)), append(vals, str(v))
+		}
+		var prev int64
+		for _, ref := range w.Nodes {
+			refs = append(refs, zz(ref-prev))
+			prev = ref
+		}
+		way = pbPacked(way, 2, keys)
+		way = pbPacked(way, 3, vals)
+		way = pbPacked(way, 8, refs)
+		ways = pbBytes(ways, 3, way)
+	}
+
+	var table []byte
+	for _, s := range strs {
+		table = pbBytes(table, 1, []byte(s))
+	}
+	block := pbBytes(nil, 1, table)
+	block = pbBytes(block, 2, nodes)
+	block = pbBytes(block, 2, denseGroup)
+	block = pbBytes(block, 2, ways)
+	block = pbVarint(block, 17, 100)
+
+	var packed bytes.Buffer
+	zw := zlib.NewWriter(&packed)
+	if _, err := zw.Write(block); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := pbVarint(nil, 2, uint64(len(block)))
+	data = pbBytes(data, 3, packed.Bytes())
+
+	var file []byte
+	file = pbBlob(file, "OSMHeader", pbBytes(nil, 1, header))
+	file = pbBlob(file, "OSMData", data)
+	return file
+}
+
+func pbBlob(file []byte, kind string, blob []byte) []byte {
+	header := pbBytes(nil, 1, []byte(kind))
+	header = pbVarint(header, 3, uint64(len(blob)))
+	file = binary.BigEndian.AppendUint32(file, uint32(len(header)))
+	return append(append(file, header...), blob...)
+}
+
+func pbVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func pbBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbPacked(b []byte, field int, vs []uint64) []byte {
+	var packed []byte
+	for _, v := range vs {
+		packed = binary.AppendUvarint(packed, v)
+	}
+	return pbBytes(b, field, packed)
+}
+
+func zz(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
@@ -0,0 +1,529 @@
+package streets
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OSM PBF (https://wiki.openstreetmap.org/wiki/PBF_Format) is a sequence of
+// blobs, each a length-prefixed BlobHeader and a Blob holding a zlib-packed
+// HeaderBlock or PrimitiveBlock. Only what the walking network needs is
+// decoded: nodes, dense or not, and ways with their tags. The few protobuf
+// messages involved are read by hand rather than through generated code.
+
+const (
+	maxBlobHeaderSize = 64 << 10
+	maxBlobSize       = 32 << 20
+)
+
+// pbfFeatures are the required features of a HeaderBlock this reader handles.
+var pbfFeatures = map[string]bool{"OsmSchema-V0.6": true, "DenseNodes": true}
+
+// readPBF streams an OSM PBF file.
+func readPBF(r io.Reader) ([]osmNode, []osmWay, error) {
+	br := bufio.NewReader(r)
+	var nodes []osmNode
+	var ways []osmWay
+	var size [4]byte
+	var header, blob []byte
+	for {
+		if _, err := io.ReadFull(br, size[:]); err == io.EOF {
+			return nodes, ways, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxBlobHeaderSize {
+			return nil, nil, fmt.Errorf("blob header of %d bytes", n)
+		}
+		header = grow(header, int(n))
+		if _, err := io.ReadFull(br, header); err != nil {
+			return nil, nil, err
+		}
+		kind, dataSize, err := blobHeader(header)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dataSize > maxBlobSize {
+			return nil, nil, fmt.Errorf("%s blob of %d bytes", kind, dataSize)
+		}
+		blob = grow(blob, dataSize)
+		if _, err := io.ReadFull(br, blob); err != nil {
+			return nil, nil, err
+		}
+		data, err := blobData(blob)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s blob: %w", kind, err)
+		}
+
+		switch kind {
+		case "OSMHeader":
+			err = headerBlock(data)
+		case "OSMData":
+			nodes, ways, err = primitiveBlock(data, nodes, ways)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s blob: %w", kind, err)
+		}
+	}
+}
+
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+// blobHeader returns the type of the blob and its size.
+func blobHeader(b []byte) (kind string, size int, err error) {
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case field == 1 && wire == wireBytes:
+			v, err := m.bytes()
+			if err != nil {
+				return "", 0, err
+			}
+			kind = string(v)
+		case field == 3 && wire == wireVarint:
+			v, err := m.varint()
+			if err != nil {
+				return "", 0, err
+			}
+			size = int(v)
+		default:
+			if err := m.skip(wire); err != nil {
+				return "", 0, err
+			}
+		}
+	}
+	return kind, size, nil
+}
+
+// blobData returns the content of a raw or zlib-packed blob.
+func blobData(b []byte) ([]byte, error) {
+	m := protoMsg(b)
+	var rawSize int
+	var zdata []byte
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case field == 1 && wire == wireBytes:
+			return m.bytes()
+		case field == 2 && wire == wireVarint:
+			v, err := m.varint()
+			if err != nil {
+				return nil, err
+			}
+			rawSize = int(v)
+		case field == 3 && wire == wireBytes:
+			if zdata, err = m.bytes(); err != nil {
+				return nil, err
+			}
+		case field >= 4 && field <= 7:
+			return nil, fmt.Errorf("compression %d is not supported, only zlib", field)
+		default:
+			if err := m.skip(wire); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if zdata == nil {
+		return nil, errors.New("no data")
+	}
+	if rawSize > maxBlobSize {
+		return nil, fmt.Errorf("%d bytes unpacked", rawSize)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(zdata))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, rawSize)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// headerBlock checks that the file needs no feature beyond pbfFeatures.
+func headerBlock(b []byte) error {
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return err
+		}
+		if field != 4 || wire != wireBytes {
+			if err := m.skip(wire); err != nil {
+				return err
+			}
+			continue
+		}
+		feature, err := m.bytes()
+		if err != nil {
+			return err
+		}
+		if !pbfFeatures[string(feature)] {
+			return fmt.Errorf("required feature %q is not supported", feature)
+		}
+	}
+	return nil
+}
+
+// block is the context of the groups of a PrimitiveBlock: its string table
+// and the scale of its coordinates.
+type block struct {
+	strings     [][]byte
+	granularity int64 // nanodegrees
+	latOffset   int64
+	lonOffset   int64
+}
+
+func (bl *block) lat(v int64) float64 {
+	return 1e-9 * float64(bl.latOffset+bl.granularity*v)
+}
+
+func (bl *block) lon(v int64) float64 {
+	return 1e-9 * float64(bl.lonOffset+bl.granularity*v)
+}
+
+func (bl *block) str(i uint64) (string, error) {
+	if i >= uint64(len(bl.strings)) {
+		return "", fmt.Errorf("string %d out of a table of %d", i, len(bl.strings))
+	}
+	return string(bl.strings[i]), nil
+}
+
+// primitiveBlock appends the nodes and ways of a block.
+func primitiveBlock(b []byte, nodes []osmNode, ways []osmWay) ([]osmNode, []osmWay, error) {
+	bl := block{granularity: 100}
+	var groups [][]byte
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case field == 1 && wire == wireBytes:
+			table, err := m.bytes()
+			if err != nil {
+				return nil, nil, err
+			}
+			if bl.strings, err = stringTable(table); err != nil {
+				return nil, nil, err
+			}
+		case field == 2 && wire == wireBytes:
+			g, err := m.bytes()
+			if err != nil {
+				return nil, nil, err
+			}
+			groups = append(groups, g) // decoded once the whole block is read
+		case (field == 17 || field == 19 || field == 20) && wire == wireVarint:
+			v, err := m.varint()
+			if err != nil {
+				return nil, nil, err
+			}
+			switch field {
+			case 17:
+				bl.granularity = int64(v)
+			case 19:
+				bl.latOffset = int64(v)
+			case 20:
+				bl.lonOffset = int64(v)
+			}
+		default:
+			if err := m.skip(wire); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	for _, g := range groups {
+		m := protoMsg(g)
+		for len(m) > 0 {
+			field, wire, err := m.key()
+			if err != nil {
+				return nil, nil, err
+			}
+			if wire != wireBytes || field < 1 || field > 3 {
+				if err := m.skip(wire); err != nil {
+					return nil, nil, err
+				}
+				continue // relations and changesets
+			}
+			v, err := m.bytes()
+			if err != nil {
+				return nil, nil, err
+			}
+			switch field {
+			case 1:
+				var n osmNode
+				if n, err = bl.node(v); err == nil {
+					nodes = append(nodes, n)
+				}
+			case 2:
+				nodes, err = bl.denseNodes(v, nodes)
+			case 3:
+				var w osmWay
+				if w, err = bl.way(v); err == nil {
+					ways = append(ways, w)
+				}
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return nodes, ways, nil
+}
+
+func stringTable(b []byte) ([][]byte, error) {
+	var table [][]byte
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 || wire != wireBytes {
+			if err := m.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		s, err := m.bytes()
+		if err != nil {
+			return nil, err
+		}
+		table = append(table, s)
+	}
+	return table, nil
+}
+
+// node decodes a Node. Its tags do not matter to the walking network.
+func (bl *block) node(b []byte) (osmNode, error) {
+	var id, lat, lon int64
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return osmNode{}, err
+		}
+		if wire != wireVarint || (field != 1 && field != 8 && field != 9) {
+			if err := m.skip(wire); err != nil {
+				return osmNode{}, err
+			}
+			continue
+		}
+		v, err := m.varint()
+		if err != nil {
+			return osmNode{}, err
+		}
+		switch field {
+		case 1:
+			id = zigzag(v)
+		case 8:
+			lat = zigzag(v)
+		case 9:
+			lon = zigzag(v)
+		}
+	}
+	return osmNode{ID: id, Lat: bl.lat(lat), Lon: bl.lon(lon)}, nil
+}
+
+// denseNodes appends the nodes of a DenseNodes, whose ids and coordinates
+// are delta-coded.
+func (bl *block) denseNodes(b []byte, nodes []osmNode) ([]osmNode, error) {
+	var ids, lats, lons []int64
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return nil, err
+		}
+		var dst *[]int64
+		switch field {
+		case 1:
+			dst = &ids
+		case 8:
+			dst = &lats
+		case 9:
+			dst = &lons
+		default:
+			if err := m.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := m.repeated(wire, func(v uint64) { *dst = append(*dst, zigzag(v)) }); err != nil {
+			return nil, err
+		}
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return nil, fmt.Errorf("dense nodes: %d ids, %d latitudes, %d longitudes", len(ids), len(lats), len(lons))
+	}
+	var id, lat, lon int64
+	for i := range ids {
+		id, lat, lon = id+ids[i], lat+lats[i], lon+lons[i]
+		nodes = append(nodes, osmNode{ID: id, Lat: bl.lat(lat), Lon: bl.lon(lon)})
+	}
+	return nodes, nil
+}
+
+// way decodes a Way, whose node refs are delta-coded.
+func (bl *block) way(b []byte) (osmWay, error) {
+	var keys, vals []uint64
+	var w osmWay
+	var ref int64
+	m := protoMsg(b)
+	for len(m) > 0 {
+		field, wire, err := m.key()
+		if err != nil {
+			return osmWay{}, err
+		}
+		switch field {
+		case 2:
+			err = m.repeated(wire, func(v uint64) { keys = append(keys, v) })
+		case 3:
+			err = m.repeated(wire, func(v uint64) { vals = append(vals, v) })
+		case 8:
+			err = m.repeated(wire, func(v uint64) {
+				ref += zigzag(v)
+				w.Nodes = append(w.Nodes, ref)
+			})
+		default:
+			err = m.skip(wire)
+		}
+		if err != nil {
+			return osmWay{}, err
+		}
+	}
+	if len(keys) != len(vals) {
+		return osmWay{}, fmt.Errorf("way with %d keys and %d values", len(keys), len(vals))
+	}
+	w.Tags = make(map[string]string, len(keys))
+	for i := range keys {
+		k, err := bl.str(keys[i])
+		if err != nil {
+			return osmWay{}, err
+		}
+		v, err := bl.str(vals[i])
+		if err != nil {
+			return osmWay{}, err
+		}
+		w.Tags[k] = v
+	}
+	return w, nil
+}
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// protoMsg is the unread rest of a protobuf message.
+type protoMsg []byte
+
+func (m *protoMsg) varint() (uint64, error) {
+	v, n := binary.Uvarint(*m)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	*m = (*m)[n:]
+	return v, nil
+}
+
+// key reads the field number and wire type of the next field.
+func (m *protoMsg) key() (field, wire int, err error) {
+	v, err := m.varint()
+	return int(v >> 3), int(v & 7), err
+}
+
+func (m *protoMsg) bytes() ([]byte, error) {
+	n, err := m.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(*m)) {
+		return nil, errTruncated
+	}
+	b := (*m)[:n]
+	*m = (*m)[n:]
+	return b, nil
+}
+
+// skip passes over a field of the given wire type.
+func (m *protoMsg) skip(wire int) error {
+	var n int
+	switch wire {
+	case wireVarint:
+		_, err := m.varint()
+		return err
+	case wireBytes:
+		_, err := m.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("protobuf wire type %d is not supported", wire)
+	}
+	if len(*m) < n {
+		return errTruncated
+	}
+	*m = (*m)[n:]
+	return nil
+}
+
+// repeated reads a repeated varint field, packed or not, calling fn with each
+// value.
+func (m *protoMsg) repeated(wire int, fn func(uint64)) error {
+	if wire == wireVarint {
+		v, err := m.varint()
+		if err == nil {
+			fn(v)
+		}
+		return err
+	}
+	if wire != wireBytes {
+		return fmt.Errorf("protobuf wire type %d for a varint", wire)
+	}
+	b, err := m.bytes()
+	if err != nil {
+		return err
+	}
+	packed := protoMsg(b)
+	for len(packed) > 0 {
+		v, err := packed.varint()
+		if err != nil {
+			return err
+		}
+		fn(v)
+	}
+	return nil
+}
+
+// zigzag decodes a sint64.
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/antigravity/morocco-transport/internal/handler"
 	"github.com/antigravity/morocco-transport/internal/repository"
 	"github.com/antigravity/morocco-transport/internal/routing"
+	"github.com/antigravity/morocco-transport/internal/streets"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -32,11 +33,19 @@ func main() {
 	}
 	defer pool.Close()
 
+	// Optional routing snapshot (see cmd/snapshot): routing starts from it and
+	// keeps working without the database
+	snapshotPath := os.Getenv("ROUTING_SNAPSHOT")
+
 	// Verify connection
 	if err := pool.Ping(context.Background()); err != nil {
-		log.Fatal("Unable to connect to database:", err)
+		if snapshotPath == "" {
+			log.Fatal("Unable to connect to database:", err)
+		}
+		log.Println("⚠️ Database unavailable, routing from the snapshot only:", err)
+	} else {
+		log.Println("✅ Connected to PostGIS database")
 	}
-	log.Println("✅ Connected to PostGIS database")
 
 	// Router setup
 	r := chi.NewRouter()
@@ -62,14 +71,68 @@ func main() {
 	
 	// Load Routing Data
 	loader := routing.NewLoader(pool)
-	raptorData, err := loader.LoadData(context.Background())
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load RAPTOR data: %v\n", err)
-		os.Exit(1)
+
+	// Optional average speeds per line type, e.g. LINE_SPEEDS_KMH="tram=18,bus=12"
+	if speeds := os.Getenv("LINE_SPEEDS_KMH"); speeds != "" {
+		parsed, err := routing.ParseSpeeds(speeds)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid LINE_SPEEDS_KMH: %v\n", err)
+			os.Exit(1)
+		}
+		loader.Speeds = parsed
+	}
+
+	// Optional street network for walking, from a local OSM extract
+	if streetsPath := os.Getenv("STREETS_OSM"); streetsPath != "" {
+		graph, err := streets.Load(streetsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load street network: %v\n", err)
+			os.Exit(1)
+		}
+		log.Printf("Loaded street network with %d nodes", graph.NumNodes())
+		loader.Streets = graph
+	}
+
+	// Reloads rebuild from the database and refresh the snapshot file
+	loadData := func(ctx context.Context) (*routing.RaptorData, error) {
+		data, err := loader.LoadData(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if snapshotPath != "" {
+			if err := data.SaveSnapshot(snapshotPath); err != nil {
+				log.Println("Failed to write routing snapshot:", err)
+			}
+		}
+		return data, nil
+	}
+
+	var raptorData *routing.RaptorData
+	if snapshotPath != "" {
+		start := time.Now()
+		if raptorData, err = routing.LoadSnapshot(snapshotPath); err != nil {
+			log.Println("Routing snapshot unusable, loading from the database:", err)
+		} else {
+			raptorData.Streets = loader.Streets
+			log.Printf("Loaded routing snapshot %s in %v", snapshotPath, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	if raptorData == nil {
+		if raptorData, err = loadData(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load RAPTOR data: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Queries run on a snapshot that reloads swap atomically
+	engine := routing.NewEngine(routing.NewRaptor(raptorData), loadData)
+
+	// Imports can ask for a reload with NOTIFY routing_reload
+	if os.Getenv("RELOAD_ON_NOTIFY") != "false" {
+		go engine.ListenForReloads(context.Background(), pool)
 	}
-	raptorEngine := routing.NewRaptor(raptorData)
 
-	transportHandler := handler.NewTransportHandler(lineRepo, raptorEngine)
+	transportHandler := handler.NewTransportHandler(lineRepo, engine)
+	adminHandler := handler.NewAdminHandler(engine, os.Getenv("ADMIN_TOKEN"))
 
 	// Routes
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -96,6 +159,13 @@ func main() {
 		r.Get("/route", transportHandler.GetRoute)
 	})
 
+	// Admin Routes, authenticated with ADMIN_TOKEN
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(adminHandler.Authorize)
+		r.Post("/reload", adminHandler.Reload)
+		r.Get("/reload", adminHandler.ReloadStatus)
+	})
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
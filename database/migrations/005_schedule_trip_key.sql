@@ -0,0 +1,5 @@
+-- Trip identifier for schedules that give times at more than the first stop.
+-- Rows of one (line, direction, day_type) sharing a trip_key are one vehicle run.
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS trip_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_schedules_trip ON schedules(line_id, direction, day_type, trip_key);
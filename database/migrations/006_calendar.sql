@@ -0,0 +1,65 @@
+-- Service calendar, as GTFS calendar.txt / calendar_dates.txt.
+-- schedules.day_type is a service_id of this calendar.
+CREATE TABLE IF NOT EXISTS calendar (
+    service_id TEXT PRIMARY KEY,
+    monday BOOLEAN NOT NULL DEFAULT FALSE,
+    tuesday BOOLEAN NOT NULL DEFAULT FALSE,
+    wednesday BOOLEAN NOT NULL DEFAULT FALSE,
+    thursday BOOLEAN NOT NULL DEFAULT FALSE,
+    friday BOOLEAN NOT NULL DEFAULT FALSE,
+    saturday BOOLEAN NOT NULL DEFAULT FALSE,
+    sunday BOOLEAN NOT NULL DEFAULT FALSE,
+    start_date DATE, -- NULL for no bound
+    end_date DATE
+);
+
+INSERT INTO calendar (service_id, monday, tuesday, wednesday, thursday, friday, saturday, sunday) VALUES
+    ('weekday', TRUE, TRUE, TRUE, TRUE, TRUE, FALSE, FALSE),
+    ('saturday', FALSE, FALSE, FALSE, FALSE, FALSE, TRUE, FALSE),
+    ('sunday', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE, TRUE),
+    ('holiday', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE, FALSE) -- runs on public_holidays only
+ON CONFLICT (service_id) DO NOTHING;
+
+-- Dates a service runs or does not run regardless of its weekdays
+CREATE TABLE IF NOT EXISTS calendar_dates (
+    service_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    exception_type SMALLINT NOT NULL CHECK (exception_type IN (1, 2)), -- 1=added, 2=removed
+    PRIMARY KEY (service_id, date)
+);
+
+-- Public holidays run the Sunday timetable plus the holiday service.
+-- The fixed civil holidays are built into the routing engine; this table
+-- holds the Islamic ones, which follow the moon sighting.
+CREATE TABLE IF NOT EXISTS public_holidays (
+    date DATE PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+-- Expected dates: correct them once the Ministry of Habous announces the sighting.
+INSERT INTO public_holidays (date, name) VALUES
+    ('2025-03-31', 'Eid al-Fitr'),
+    ('2025-04-01', 'Eid al-Fitr'),
+    ('2025-06-07', 'Eid al-Adha'),
+    ('2025-06-08', 'Eid al-Adha'),
+    ('2025-06-27', 'Islamic New Year'),
+    ('2025-09-05', 'Mawlid'),
+    ('2025-09-06', 'Mawlid'),
+    ('2026-03-21', 'Eid al-Fitr'),
+    ('2026-03-22', 'Eid al-Fitr'),
+    ('2026-05-27', 'Eid al-Adha'),
+    ('2026-05-28', 'Eid al-Adha'),
+    ('2026-06-17', 'Islamic New Year'),
+    ('2026-08-26', 'Mawlid'),
+    ('2026-08-27', 'Mawlid'),
+    ('2027-03-10', 'Eid al-Fitr'),
+    ('2027-03-11', 'Eid al-Fitr'),
+    ('2027-05-17', 'Eid al-Adha'),
+    ('2027-05-18', 'Eid al-Adha'),
+    ('2027-06-06', 'Islamic New Year'),
+    ('2027-08-15', 'Mawlid'),
+    ('2027-08-16', 'Mawlid')
+ON CONFLICT (date) DO NOTHING;
+
+-- Any calendar service may now have schedules, not only the four day types
+ALTER TABLE schedules DROP CONSTRAINT IF EXISTS schedules_day_type_check;
@@ -0,0 +1,16 @@
+-- Seasonal timetables: while a season runs, every service S is replaced by
+-- <name>_S (ramadan_weekday, ramadan_sunday...) on the lines that have one.
+CREATE TABLE IF NOT EXISTS seasons (
+    name TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL, -- last day of the season
+    PRIMARY KEY (name, start_date),
+    CHECK (end_date >= start_date)
+);
+
+-- Expected dates: correct them once the Ministry of Habous announces the sighting.
+INSERT INTO seasons (name, start_date, end_date) VALUES
+    ('ramadan', '2025-03-02', '2025-03-30'),
+    ('ramadan', '2026-02-19', '2026-03-20'),
+    ('ramadan', '2027-02-08', '2027-03-09')
+ON CONFLICT (name, start_date) DO NOTHING;
@@ -0,0 +1,23 @@
+-- Ask the API servers to reload their routing data when the network changes.
+-- They listen on the routing_reload channel and wait for a quiet moment, so
+-- an import touching many rows still causes a single reload.
+CREATE OR REPLACE FUNCTION notify_routing_reload() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('routing_reload', TG_TABLE_NAME);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['stops', 'lines', 'line_stops', 'schedules', 'fares', 'transfers',
+                             'calendar', 'calendar_dates', 'public_holidays', 'seasons']
+    LOOP
+        EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', t || '_routing_reload', t);
+        EXECUTE format('CREATE TRIGGER %I AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %I
+                        FOR EACH STATEMENT EXECUTE FUNCTION notify_routing_reload()', t || '_routing_reload', t);
+    END LOOP;
+END;
+$$;
@@ -0,0 +1,11 @@
+-- Departures after midnight belong to the service day they started on and go
+-- past 24:00:00, as in GTFS: 25:10:00 is 01:10 the next morning. A TIME cannot
+-- hold them; an INTERVAL can, and reads back in the same HH:MM:SS form.
+ALTER TABLE schedules ALTER COLUMN departure_time TYPE INTERVAL USING departure_time::interval;
+
+-- Hours, minutes and seconds only: '1 day 01:10' would read back as such
+-- rather than 25:10:00.
+ALTER TABLE schedules DROP CONSTRAINT IF EXISTS schedules_departure_time_check;
+ALTER TABLE schedules ADD CONSTRAINT schedules_departure_time_check
+    CHECK (departure_time >= INTERVAL '0' AND departure_time < INTERVAL '48 hours'
+           AND EXTRACT(DAY FROM departure_time) = 0);
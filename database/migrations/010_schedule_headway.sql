@@ -0,0 +1,5 @@
+-- Departures generated from an "every N min" range rather than published:
+-- headway_secs is the spacing they were generated at, NULL for a published
+-- time. The routing engine serves runs of these as frequencies, and only
+-- these, as a timetable can be evenly spaced too.
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS headway_secs INTEGER CHECK (headway_secs > 0);
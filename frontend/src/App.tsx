@@ -418,7 +418,9 @@ function App() {
         return { ok: false as const, message: text || 'No route found between these stops' }
       }
 
-      const journey = await res.json()
+      const data = await res.json()
+      // The API returns Pareto-optimal alternatives, fewest transfers first
+      const journey = data.journeys?.[0] ?? data
       const legs: JourneyLeg[] = decorateLegs(journey.legs || [])
       const stopsByRoute: Record<string, Station[]> = {}
       legs.forEach((leg) => {
@@ -143,7 +143,7 @@ export default function MapScreen() {
         origin: originStop,
         destination: destinationStop,
       });
-      setJourney(result);
+      setJourney(result.journeys[0] ?? null);
       
       // Expand bottom sheet to show results
       bottomSheetRef.current?.snapToIndex(2);
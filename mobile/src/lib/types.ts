@@ -62,14 +62,37 @@ export interface JourneyLeg {
   routeCode: string;
   routeColor: string;
   waitTime: number;
+  headway?: number; // seconds between departures of a frequency line
+  frequency?: string; // "every ~8 min" instead of exact times
+  fare?: number; // MAD paid when boarding
+  accessible?: boolean;
   stops?: Stop[];
   geometry?: [number, number][];
 }
 
 export interface Journey {
+  departureTime: string;
+  arrivalTime: string;
+  duration: number; // seconds
+  transfers: number;
+  fare: number; // MAD
+  cost: number;
+  departureDay: number; // days after the queried day, -1 for the day before
+  arrivalDay: number;
+  accessible: boolean;
+  departureAt?: string; // ISO-8601 in Casablanca time
+  arrivalAt?: string;
   legs: JourneyLeg[];
 }
 
+// GET /route: the Pareto-optimal alternatives, fewest transfers first
+export interface RouteResponse {
+  date: string; // YYYY-MM-DD service date the journeys were planned on
+  journeys: Journey[];
+  partial?: boolean; // the search stopped early, see warning
+  warning?: string;
+}
+
 export interface CondensedLeg {
   mode: string;
   routeCode: string;
@@ -4,7 +4,7 @@ import type {
   LineDetails,
   StopDetails,
   Stop,
-  Journey,
+  RouteResponse,
   ViewportBounds,
   RouteRequest,
   HealthResponse,
@@ -154,9 +154,9 @@ export const api = {
   },
 
   /**
-   * Get route between two points
+   * Get the journeys between two points, fewest transfers first
    */
-  async getRoute(request: RouteRequest): Promise<Journey> {
+  async getRoute(request: RouteRequest): Promise<RouteResponse> {
     const params = new URLSearchParams({
       from_lat: String(request.fromLat),
       from_lon: String(request.fromLon),
@@ -172,7 +172,7 @@ export const api = {
       params.append('day', request.day);
     }
 
-    return fetchWithTimeout<Journey>(`${API_BASE_URL}/route?${params.toString()}`);
+    return fetchWithTimeout<RouteResponse>(`${API_BASE_URL}/route?${params.toString()}`);
   },
 };
 
@@ -211,6 +211,8 @@ async function importData() {
         }
       }
       const dayTypes = ['weekday', 'saturday', 'sunday'];
+      // Marks the rows as generated, for the routing engine to serve as a frequency
+      const headwaySecs = 600;
 
       // Get all first stops for all lines
       const firstStopsResult = await client.query(
@@ -225,8 +227,8 @@ async function importData() {
       for (const row of firstStopsResult.rows) {
         for (const dayType of dayTypes) {
           for (const timeStr of departures) {
-            values.push(`($${paramIndex++}, $${paramIndex++}, $${paramIndex++}, $${paramIndex++}, $${paramIndex++})`);
-            params.push(row.line_id, row.stop_id, row.direction, dayType, timeStr);
+            values.push(`($${paramIndex++}, $${paramIndex++}, $${paramIndex++}, $${paramIndex++}, $${paramIndex++}, $${paramIndex++})`);
+            params.push(row.line_id, row.stop_id, row.direction, dayType, timeStr, headwaySecs);
           }
         }
       }
@@ -236,16 +238,16 @@ async function importData() {
         const batchSize = 1000;
         for (let i = 0; i < values.length; i += batchSize) {
           const batchValues = values.slice(i, i + batchSize);
-          const batchParams = params.slice(i * 5, (i + batchSize) * 5);
+          const batchParams = params.slice(i * 6, (i + batchSize) * 6);
           
           // Renumber parameters for batch
           const renumbered = batchValues.map((v, idx) => {
-            const baseIdx = idx * 5;
-            return `($${baseIdx + 1}, $${baseIdx + 2}, $${baseIdx + 3}, $${baseIdx + 4}, $${baseIdx + 5})`;
+            const baseIdx = idx * 6;
+            return `($${baseIdx + 1}, $${baseIdx + 2}, $${baseIdx + 3}, $${baseIdx + 4}, $${baseIdx + 5}, $${baseIdx + 6})`;
           });
 
           await client.query(
-            `INSERT INTO schedules (line_id, stop_id, direction, day_type, departure_time) VALUES ${renumbered.join(', ')}`,
+            `INSERT INTO schedules (line_id, stop_id, direction, day_type, departure_time, headway_secs) VALUES ${renumbered.join(', ')}`,
             batchParams
           );
           console.log(`  Inserted batch ${Math.floor(i / batchSize) + 1}/${Math.ceil(values.length / batchSize)}`);
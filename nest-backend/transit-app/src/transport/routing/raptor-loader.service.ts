@@ -138,7 +138,8 @@ export class RaptorLoader implements OnModuleInit {
         }
         const firstStopDbId = dbStopIds[0];
         const schedules = await this.db.query<ScheduleRow>(
-          `SELECT departure_time FROM schedules
+          // An interval, past 24:00:00 after midnight: read it as text
+          `SELECT departure_time::text AS departure_time FROM schedules
            WHERE line_id = $1 AND direction = $2 AND stop_id = $3 AND day_type = $4
            ORDER BY departure_time`,
           [lineId, dirId, firstStopDbId, dayType],
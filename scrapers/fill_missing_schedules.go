@@ -32,14 +32,16 @@ func main() {
 	}
 	defer tx.Rollback()
 
-	// Default Schedule: 06:00 - 22:00 every 30 mins
+	// Default Schedule: 06:00 - 22:00 every 30 mins, marked with its
+	// headway for the routing engine to serve as a frequency
+	headway := 30 * time.Minute
 	start, _ := time.Parse("15:04", "06:00")
 	end, _ := time.Parse("15:04", "22:00")
 	var times []string
 	curr := start
 	for curr.Before(end) || curr.Equal(end) {
 		times = append(times, curr.Format("15:04:05"))
-		curr = curr.Add(30 * time.Minute)
+		curr = curr.Add(headway)
 	}
 
 	count := 0
@@ -71,9 +73,9 @@ func main() {
 			for _, day := range []string{"weekday", "saturday", "sunday"} {
 				for _, t := range times {
 					_, err := tx.Exec(`
-						INSERT INTO schedules (line_id, stop_id, direction, day_type, departure_time)
-						VALUES ($1, $2, $3, $4, $5)
-					`, lineID, stopID, dir, day, t)
+						INSERT INTO schedules (line_id, stop_id, direction, day_type, departure_time, headway_secs)
+						VALUES ($1, $2, $3, $4, $5, $6)
+					`, lineID, stopID, dir, day, t, int(headway.Seconds()))
 					if err != nil {
 						log.Println("Error inserting schedule:", err)
 					}
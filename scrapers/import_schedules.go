@@ -41,6 +41,10 @@ type Frequencies struct {
 	MondayFriday []TimeRange `json:"monday_friday"`
 	Saturday     []TimeRange `json:"saturday"`
 	Sunday       []TimeRange `json:"sunday_holidays"`
+
+	// Ramadan replaces the timetable above while a "ramadan" season of the
+	// seasons table runs. Its ranges may go past midnight ("21:00 - 01:30").
+	Ramadan *Frequencies `json:"ramadan,omitempty"`
 }
 
 type TimeRange struct {
@@ -48,6 +52,13 @@ type TimeRange struct {
 	Interval string `json:"interval"`
 }
 
+// Departure is a generated departure time and the interval it was generated
+// at, stored as headway_secs for the routing engine to serve as a frequency.
+type Departure struct {
+	Time    string
+	Headway time.Duration
+}
+
 type BusSection struct {
 	Lines []BusLine `json:"lines"`
 }
@@ -101,8 +112,8 @@ func parseInterval(iStr string) time.Duration {
 	return time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
 }
 
-func generateDependures(ranges []TimeRange) []string {
-	var departures []string
+func generateDependures(ranges []TimeRange) []Departure {
+	var departures []Departure
 	
 	for _, r := range ranges {
 		parts := strings.Split(r.Range, "-")
@@ -117,6 +128,12 @@ func generateDependures(ranges []TimeRange) []string {
 		if err1 != nil || err2 != nil || interval == 0 {
 			continue
 		}
+		// Late-night service ends after midnight, still on the same
+		// service day: 01:30 is stored as 25:30:00
+		if end.Before(start) {
+			end = end.Add(24 * time.Hour)
+		}
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
 		
 		// If end match start of next range, we might duplicate? 
 		// Usually ranges are inclusive on start, exclusive on end or inclusive.
@@ -124,7 +141,7 @@ func generateDependures(ranges []TimeRange) []string {
 		
 		curr := start
 		for curr.Before(end) || curr.Equal(end) {
-			departures = append(departures, curr.Format("15:04:05"))
+			departures = append(departures, Departure{serviceTime(day, curr), interval})
 			curr = curr.Add(interval)
 		}
 	}
@@ -132,6 +149,13 @@ func generateDependures(ranges []TimeRange) []string {
 	return departures
 }
 
+// serviceTime formats t as HH:MM:SS since the start of its service day,
+// going past 24:00:00 after midnight.
+func serviceTime(day, t time.Time) string {
+	secs := int(t.Sub(day) / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
+
 func main() {
 	// Read JSON
 	dataBytes, err := os.ReadFile("manual_schedules.json")
@@ -210,18 +234,22 @@ func processSection(tx *sql.Tx, lines []LineSched, mode string) {
 				direction = 0
 			}
 			
-			// Generate times
-			weekdayTimes := generateDependures(sched.Frequencies.MondayFriday)
-			saturdayTimes := generateDependures(sched.Frequencies.Saturday)
-			sundayTimes := generateDependures(sched.Frequencies.Sunday)
-			
-			insertSchedules(tx, lineID, direction, "weekday", weekdayTimes)
-			insertSchedules(tx, lineID, direction, "saturday", saturdayTimes)
-			insertSchedules(tx, lineID, direction, "sunday", sundayTimes)
+			insertFrequencies(tx, lineID, direction, "", sched.Frequencies)
+			if sched.Frequencies.Ramadan != nil {
+				insertFrequencies(tx, lineID, direction, "ramadan_", *sched.Frequencies.Ramadan)
+			}
 		}
 	}
 }
 
+// insertFrequencies generates and inserts the departures of each day type.
+// prefix names the seasonal services, e.g. "ramadan_" for ramadan_weekday.
+func insertFrequencies(tx *sql.Tx, lineID, direction int, prefix string, f Frequencies) {
+	insertSchedules(tx, lineID, direction, prefix+"weekday", generateDependures(f.MondayFriday))
+	insertSchedules(tx, lineID, direction, prefix+"saturday", generateDependures(f.Saturday))
+	insertSchedules(tx, lineID, direction, prefix+"sunday", generateDependures(f.Sunday))
+}
+
 func processBuses(tx *sql.Tx, lines []BusLine) {
 	for _, bus := range lines {
 		// Clean line code: L005 -> L5, 5 -> 5
@@ -266,10 +294,10 @@ func processBuses(tx *sql.Tx, lines []BusLine) {
 				end, _ := parseTime(endStr)
 				
 				// Generate every 20 mins
-				var times []string
+				var times []Departure
 				curr := start
 				for curr.Before(end) {
-					times = append(times, curr.Format("15:04:05"))
+					times = append(times, Departure{curr.Format("15:04:05"), 20 * time.Minute})
 					curr = curr.Add(20 * time.Minute)
 				}
 				
@@ -285,7 +313,7 @@ func processBuses(tx *sql.Tx, lines []BusLine) {
 	}
 }
 
-func insertSchedules(tx *sql.Tx, lineID, direction int, dayType string, times []string) {
+func insertSchedules(tx *sql.Tx, lineID, direction int, dayType string, times []Departure) {
 	// Find all stops for this line/direction to populate schedule for *every* stop?
 	// Real GTFS has times per stop. 
 	// Basic routing needs at least departure from first stop. 
@@ -307,11 +335,11 @@ func insertSchedules(tx *sql.Tx, lineID, direction int, dayType string, times []
 		return
 	}
 	
-	stmt, _ := tx.Prepare(`INSERT INTO schedules (line_id, stop_id, direction, day_type, departure_time) VALUES ($1, $2, $3, $4, $5)`)
+	stmt, _ := tx.Prepare(`INSERT INTO schedules (line_id, stop_id, direction, day_type, departure_time, headway_secs) VALUES ($1, $2, $3, $4, $5, $6)`)
 	defer stmt.Close()
 	
 	for _, t := range times {
-		stmt.Exec(lineID, stopID, direction, dayType, t)
+		stmt.Exec(lineID, stopID, direction, dayType, t.Time, int(t.Headway.Seconds()))
 	}
 }
 